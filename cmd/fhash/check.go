@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+	"github.com/Virace/fast-hasher/internal/manifest"
+	"github.com/Virace/fast-hasher/internal/output"
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// runCheck verifies the files listed in a manifest against their recorded
+// hashes, like sha256sum -c. When the manifest has chunk hashes, the byte
+// ranges of a mismatching file that differ are reported as well.
+// It returns the process exit code.
+func runCheck(cfg *Config, s *scanner.Scanner, hashers []hasher.Hasher, formatter output.Formatter) int {
+	if len(cfg.Paths) > 0 || cfg.FromStdin || cfg.FromFile != "" {
+		fmt.Fprintln(os.Stderr, "Error: check mode reads the files to verify from the manifest")
+		return 1
+	}
+
+	entries, err := manifest.ParseFile(cfg.Check, hashers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no files to check in %s\n", cfg.Check)
+		return 1
+	}
+
+	// Recompute chunk hashes with the recorded chunk size unless overridden
+	if s.ChunkSize == 0 {
+		for _, e := range entries {
+			if e.ChunkSize > 0 {
+				s.ChunkSize = e.ChunkSize
+				break
+			}
+		}
+	}
+
+	byPath := make(map[string]manifest.Entry, len(entries))
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		byPath[e.Path] = e
+		paths[i] = e.Path
+	}
+
+	// Manifest paths are reported as recorded
+	s.AbsolutePath = false
+
+	total, failed, errors := 0, 0, 0
+	for result := range s.ScanFiles(paths) {
+		total++
+		if result.IsError() {
+			errors++
+			if !cfg.Machine {
+				fmt.Fprintln(os.Stderr, formatter.FormatError(result))
+			} else if cfg.JSON {
+				fmt.Println(formatter.FormatError(result))
+			}
+			continue
+		}
+		if result.Skipped {
+			result.Error = fmt.Errorf("not a regular file (%s)", result.Type)
+			errors++
+			fmt.Fprintln(os.Stderr, formatter.FormatError(result))
+			continue
+		}
+
+		ok, ranges, err := checkResult(byPath[result.Path], s.ChunkSize, hashers, result)
+		if err != nil {
+			errors++
+			result.Error = err
+			fmt.Fprintln(os.Stderr, formatter.FormatError(result))
+			continue
+		}
+		if !ok {
+			failed++
+		}
+		fmt.Println(formatCheck(cfg.JSON, result.Path, ok, ranges))
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %d of %d files did NOT match\n", failed, total)
+	}
+	if errors > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %d of %d files could not be checked\n", errors, total)
+	}
+	if failed > 0 || errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkResult compares a scanned file with its manifest entry. For a
+// mismatch, the differing byte ranges are returned if the entry has chunk
+// hashes of the given chunk size for a mismatching algorithm.
+func checkResult(entry manifest.Entry, chunkSize int64, hashers []hasher.Hasher, result *scanner.Result) (bool, []manifest.Range, error) {
+	ok := true
+	var ranges []manifest.Range
+	for _, h := range hashers {
+		equal, err := entry.Equal(h, result.Hashes[h.Name()])
+		if err != nil {
+			return false, nil, err
+		}
+		if equal {
+			continue
+		}
+		ok = false
+
+		got, have := result.Chunks[h.Name()]
+		if ranges == nil && have && entry.ChunkSize == chunkSize {
+			if r, err := entry.DiffChunks(h, got, result.Size); err == nil {
+				ranges = r
+			}
+		}
+	}
+	return ok, ranges, nil
+}
+
+// formatCheck formats the verification result of a file.
+// Text: "path: OK", "path: FAILED" or "path: FAILED (bytes 0-1023, ...)".
+// JSON: {"path": ..., "status": "ok"|"failed", "ranges": [{"offset", "length"}]}.
+func formatCheck(asJSON bool, path string, ok bool, ranges []manifest.Range) string {
+	if asJSON {
+		data := map[string]interface{}{"path": path, "status": "ok"}
+		if !ok {
+			data["status"] = "failed"
+			if ranges != nil {
+				list := make([]map[string]int64, len(ranges))
+				for i, r := range ranges {
+					list[i] = map[string]int64{"offset": r.Offset, "length": r.Length}
+				}
+				data["ranges"] = list
+			}
+		}
+		b, _ := json.Marshal(data)
+		return string(b)
+	}
+
+	if ok {
+		return path + ": OK"
+	}
+	if len(ranges) == 0 {
+		return path + ": FAILED"
+	}
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = fmt.Sprintf("%d-%d", r.Offset, r.Offset+r.Length-1)
+	}
+	return fmt.Sprintf("%s: FAILED (bytes %s)", path, strings.Join(parts, ", "))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+	"github.com/Virace/fast-hasher/internal/output"
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// writeManifest hashes paths with hashers and writes the output of
+// formatter to a manifest file.
+func writeManifest(t *testing.T, hashers []hasher.Hasher, sample *hasher.Sample, formatter output.Formatter, paths []string) string {
+	t.Helper()
+	s := scanner.NewScanner(hashers)
+	s.Sample = sample
+	var lines []string
+	for result := range s.ScanFiles(paths) {
+		if result.IsError() {
+			t.Fatalf("scan %s: %v", result.Path, result.Error)
+		}
+		lines = append(lines, formatter.Format(result))
+	}
+	manifest := filepath.Join(t.TempDir(), "manifest")
+	if err := os.WriteFile(manifest, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+// check runs check mode against a manifest and returns the exit code.
+func check(hashers []hasher.Hasher, sample *hasher.Sample, manifest string) int {
+	s := scanner.NewScanner(hashers)
+	s.Sample = sample
+	names := make([]string, len(hashers))
+	for i, h := range hashers {
+		names[i] = h.Name()
+	}
+	return runCheck(&Config{Check: manifest}, s, hashers, output.NewTextFormatter(names))
+}
+
+func TestRunCheck_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte(strings.Repeat("hello world\n", 1000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := hasher.Parse("sha256,md5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyed, err := hasher.Keyed(base, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample := &hasher.Sample{HeadTail: 1024}
+	sampled, err := hasher.Sampled(base[:1], *sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		hashers []hasher.Hasher
+		sample  *hasher.Sample
+	}{
+		{name: "hmac", hashers: keyed},
+		{name: "single hmac", hashers: keyed[:1]},
+		{name: "sampled", hashers: sampled, sample: sample},
+	}
+	for _, tt := range tests {
+		names := make([]string, len(tt.hashers))
+		for i, h := range tt.hashers {
+			names[i] = h.Name()
+		}
+		text := output.NewTextFormatter(names)
+		text.Labeled = true
+		formatters := map[string]output.Formatter{"text": text, "json": output.NewJSONFormatter()}
+
+		for format, formatter := range formatters {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				manifest := writeManifest(t, tt.hashers, tt.sample, formatter, []string{path})
+				if rc := check(tt.hashers, tt.sample, manifest); rc != 0 {
+					t.Errorf("check of the written manifest = %d, want 0", rc)
+				}
+				// The plain digests are not what was recorded
+				if rc := check(base, nil, manifest); rc == 0 {
+					t.Error("check without the key or sample = 0, want failure")
+				}
+			})
+		}
+	}
+}
+
+func TestRunCheck_NothingToCheck(t *testing.T) {
+	hashers, err := hasher.Parse("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{name: "empty", manifest: ""},
+		{name: "comments only", manifest: "# ERROR: b.txt: file not found\n"},
+		{name: "symlinks only", manifest: `{"path":"link","type":"symlink","target":"a.txt"}` + "\n"},
+		{name: "no checkable algorithm", manifest: `{"path":"` + filepath.ToSlash(path) + `","type":"file","md5":"5d41402abc4b2a76b9719d911017c592"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := filepath.Join(dir, "manifest")
+			if err := os.WriteFile(manifest, []byte(tt.manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if rc := check(hashers, nil, manifest); rc == 0 {
+				t.Error("check = 0, want failure")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+	"github.com/Virace/fast-hasher/internal/manifest"
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// runCompare computes fuzzy hashes for all scanned files and reports the
+// similarity of every pair of files, or of every file against every entry
+// of a manifest. It returns the process exit code.
+func runCompare(cfg *Config, hashers []hasher.Hasher, results <-chan *scanner.Result) int {
+	fuzzy := make([]hasher.FuzzyHasher, 0, len(hashers))
+	for _, h := range hashers {
+		fh, ok := h.(hasher.FuzzyHasher)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: algorithm %s does not support comparison (use a fuzzy hash such as ssdeep or tlsh)\n", h.Name())
+			return 1
+		}
+		fuzzy = append(fuzzy, fh)
+	}
+
+	var refs []manifest.Entry
+	if cfg.Manifest != "" {
+		var err error
+		refs, err = manifest.ParseFile(cfg.Manifest, hashers)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	hasError := false
+	var files []*scanner.Result
+	for result := range results {
+		if result.IsError() {
+			hasError = true
+			fmt.Fprintf(os.Stderr, "# ERROR: %s: %s\n", result.Path, result.Error)
+			continue
+		}
+		if result.Type == scanner.TypeSymlink || result.Skipped {
+			continue // Nothing to compare
+		}
+		files = append(files, result)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	report := func(fh hasher.FuzzyHasher, path, match string, a, b string) {
+		score, err := fh.Compare(a, b)
+		if err != nil {
+			return // e.g. a TLSH digest of a too small input
+		}
+		if cfg.Threshold >= 0 {
+			if fh.IsDistance() && score > cfg.Threshold || !fh.IsDistance() && score < cfg.Threshold {
+				return
+			}
+		}
+		fmt.Println(formatComparison(cfg.JSON, fh, path, match, score))
+	}
+
+	for _, fh := range fuzzy {
+		name := fh.Name()
+		if refs != nil {
+			for _, f := range files {
+				for _, ref := range refs {
+					if digest, ok := ref.Hashes[name]; ok {
+						report(fh, f.Path, ref.Path, f.Hashes[name], digest)
+					}
+				}
+			}
+			continue
+		}
+
+		for i := range files {
+			for j := i + 1; j < len(files); j++ {
+				report(fh, files[i].Path, files[j].Path, files[i].Hashes[name], files[j].Hashes[name])
+			}
+		}
+	}
+
+	if hasError && cfg.OnError == "fail" {
+		return 1
+	}
+	return 0
+}
+
+// formatComparison formats a single comparison result.
+// Text: "algo:score  path  match"
+// JSON: {"path":..., "match":..., "algorithm":..., "score"|"distance":...}
+func formatComparison(asJSON bool, fh hasher.FuzzyHasher, path, match string, score int) string {
+	if !asJSON {
+		return fmt.Sprintf("%s:%d  %s  %s", fh.Name(), score, path, match)
+	}
+
+	data := map[string]interface{}{
+		"path":      path,
+		"match":     match,
+		"algorithm": fh.Name(),
+	}
+	if fh.IsDistance() {
+		data["distance"] = score
+	} else {
+		data["score"] = score
+	}
+	b, _ := json.Marshal(data)
+	return string(b)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/ratelimit"
+)
+
+// startControl listens on a Unix socket for commands that adjust the rate
+// limits of a running scan, one per line:
+//
+//	limit-rate <size>   set the read bandwidth limit per second (0 = unlimited)
+//	limit-files <n>     set the files per second limit (0 = unlimited)
+//	status              show the current limits
+//
+// Every command is answered with a single line. A socket left behind by a
+// process that is no longer running is replaced.
+func startControl(path string, limiter *ratelimit.Limiter) (io.Closer, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control socket %s is in use", path)
+		}
+		os.Remove(path)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			go serveControl(conn, limiter)
+		}
+	}()
+	return ln, nil
+}
+
+// serveControl answers the commands of one control connection.
+func serveControl(conn net.Conn, limiter *ratelimit.Limiter) {
+	defer conn.Close()
+
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		fields := strings.Fields(lines.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		fmt.Fprintln(conn, controlCommand(fields, limiter))
+	}
+}
+
+// controlCommand executes a control command and returns the reply.
+func controlCommand(fields []string, limiter *ratelimit.Limiter) string {
+	switch {
+	case fields[0] == "status" && len(fields) == 1:
+		bytes, files := limiter.Limits()
+		return fmt.Sprintf("limit-rate %d limit-files %g", int64(bytes), files)
+	case fields[0] == "limit-rate" && len(fields) == 2:
+		rate, err := parseSize(fields[1])
+		if err != nil || rate < 0 {
+			return fmt.Sprintf("error: invalid rate: %s", fields[1])
+		}
+		limiter.SetBytes(float64(rate))
+		return "ok"
+	case fields[0] == "limit-files" && len(fields) == 2:
+		rate, err := parseFileRate(fields[1])
+		if err != nil {
+			return fmt.Sprintf("error: %v", err)
+		}
+		limiter.SetFiles(rate)
+		return "ok"
+	default:
+		return "error: usage: limit-rate <size> | limit-files <n> | status"
+	}
+}
+
+// parseFileRate parses a files per second limit, which may be fractional.
+func parseFileRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(rate >= 0) || math.IsInf(rate, 1) {
+		return 0, fmt.Errorf("invalid rate: %s", s)
+	}
+	return rate, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Virace/fast-hasher/internal/ratelimit"
+)
+
+func TestControlCommand(t *testing.T) {
+	limiter := ratelimit.New(0, 0)
+
+	// Commands run in order on the same limiter; status shows the limits
+	// after each of them
+	tests := []struct {
+		command string
+		reply   string
+		status  string
+	}{
+		{"status", "limit-rate 0 limit-files 0", "limit-rate 0 limit-files 0"},
+		{"limit-rate 10MB", "ok", "limit-rate 10485760 limit-files 0"},
+		{"limit-files 2.5", "ok", "limit-rate 10485760 limit-files 2.5"},
+		{"limit-rate 512", "ok", "limit-rate 512 limit-files 2.5"},
+		{"limit-rate abc", "error: invalid rate: abc", "limit-rate 512 limit-files 2.5"},
+		{"limit-rate -1MB", "error: invalid rate: -1MB", "limit-rate 512 limit-files 2.5"},
+		{"limit-files -1", "error: invalid rate: -1", "limit-rate 512 limit-files 2.5"},
+		{"limit-files NaN", "error: invalid rate: NaN", "limit-rate 512 limit-files 2.5"},
+		{"limit-files +Inf", "error: invalid rate: +Inf", "limit-rate 512 limit-files 2.5"},
+		{"limit-rate", "error: usage: limit-rate <size> | limit-files <n> | status", "limit-rate 512 limit-files 2.5"},
+		{"status now", "error: usage: limit-rate <size> | limit-files <n> | status", "limit-rate 512 limit-files 2.5"},
+		{"pause", "error: usage: limit-rate <size> | limit-files <n> | status", "limit-rate 512 limit-files 2.5"},
+		{"limit-rate 0", "ok", "limit-rate 0 limit-files 2.5"},
+		{"limit-files 0", "ok", "limit-rate 0 limit-files 0"},
+	}
+	for _, tt := range tests {
+		if got := controlCommand(strings.Fields(tt.command), limiter); got != tt.reply {
+			t.Errorf("%q: reply = %q, want %q", tt.command, got, tt.reply)
+		}
+		if got := controlCommand([]string{"status"}, limiter); got != tt.status {
+			t.Errorf("%q: status = %q, want %q", tt.command, got, tt.status)
+		}
+	}
+}
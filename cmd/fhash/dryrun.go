@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Virace/fast-hasher/internal/output"
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// runDryRun walks the inputs with the configured filters and lists the files
+// that would be hashed with their sizes, without reading them, followed by
+// the number of files and bytes. With --explain, the files and directories
+// left out are listed with the reason.
+// It returns the process exit code.
+func runDryRun(cfg *Config, s *scanner.Scanner) int {
+	s.DryRun = true
+	s.Explain = cfg.Explain
+
+	summary := output.NewDryRunSummary()
+	errorFormatter := output.Formatter(output.NewTextFormatter(nil))
+	if cfg.JSON {
+		errorFormatter = output.NewJSONFormatter()
+	}
+	for result := range scanInputs(cfg, s) {
+		summary.Add(result)
+		if !result.IsError() {
+			fmt.Println(output.FormatDryRun(result, cfg.JSON))
+		} else if !cfg.Machine {
+			fmt.Fprintln(os.Stderr, errorFormatter.FormatError(result))
+		} else if cfg.JSON {
+			fmt.Println(errorFormatter.FormatError(result))
+		}
+	}
+	fmt.Println(summary.Format(cfg.JSON))
+
+	if summary.Errors > 0 && s.OnError == scanner.FailOnError {
+		return 1
+	}
+	return 0
+}
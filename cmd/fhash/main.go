@@ -3,16 +3,25 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Virace/fast-hasher/internal/hasher"
 	"github.com/Virace/fast-hasher/internal/output"
+	"github.com/Virace/fast-hasher/internal/ratelimit"
 	"github.com/Virace/fast-hasher/internal/scanner"
+	"github.com/Virace/fast-hasher/pkg/fastcdc"
 )
 
 // Version information (set by build flags)
@@ -28,14 +37,20 @@ type Config struct {
 	Algo string
 
 	// Input mode
-	Paths     []string
-	FromFile  string
-	FromStdin bool
-	Recursive bool
+	Paths          []string
+	FromFile       string
+	FromStdin      bool
+	Recursive      bool
+	MaxDepth       int
+	MinDepth       int
+	ExcludeDir     string
+	SkipHiddenDirs bool
 
 	// Output mode
+	Encoding     string
 	Machine      bool
 	JSON         bool
+	SRI          bool
 	AbsolutePath bool
 
 	// Error handling
@@ -48,9 +63,82 @@ type Config struct {
 	ExcludeExt string
 	Include    string
 	Exclude    string
+	IgnoreCase bool
+	GitIgnore  bool
+	IgnoreFile string
+	NoIgnore   bool
+	// Regexps are repeatable flags, since commas are common in them
+	IncludeRegex []string
+	ExcludeRegex []string
+	NewerThan    string
+	OlderThan    string
+	ChangedNewer string
+	ChangedOlder string
+	Owner        string
+	Group        string
+	Perm         string
+	SkipHidden   bool
+	Filter       string
+	DryRun       bool
+	Explain      bool
 
-	// Concurrency
-	Workers int
+	// Concurrency and I/O
+	Workers    int
+	BufferSize string
+	Mmap       bool
+	DropCache  bool
+	Direct     bool
+	PerDevice  bool
+	Order      string
+	Symlinks   string
+	Hardlinks  bool
+	LinkReport bool
+	OneFS      bool
+	BlockDevs  bool
+	ExcludeFS  string
+	HDDWorkers int
+	SSDWorkers int
+
+	// Throttling
+	LimitRate     string
+	LimitFiles    string
+	ControlSocket string
+
+	// BitTorrent mode
+	Torrent     string
+	TorrentOut  string
+	PieceLength string
+
+	// Keyed hashing (the key is never accepted as a CLI argument)
+	HMACKeyFile string
+	HMACKeyEnv  string
+
+	// Tree hash mode
+	Tree      bool
+	TreeDirs  bool
+	TreeModes bool
+
+	// Chunk hashes and verification
+	ChunkSize string
+	Check     string
+
+	// Sampling (hash only part of each file)
+	Offset     string
+	Length     string
+	HeadTail   string
+	Samples    int
+	SampleSize string
+
+	// Content-defined chunking
+	CDC    bool
+	CDCMin string
+	CDCAvg string
+	CDCMax string
+
+	// Compare mode
+	Compare   bool
+	Manifest  string
+	Threshold int
 
 	// Other
 	ListAlgos bool
@@ -77,53 +165,315 @@ func main() {
 		os.Exit(0)
 	}
 
-	if cfg.Algo == "" {
+	if cfg.Torrent != "" {
+		os.Exit(runTorrent(cfg))
+	}
+
+	if cfg.SRI && cfg.Algo == "" {
+		cfg.Algo = "sha384"
+	}
+
+	// A dry run reads no files, so it needs no algorithm
+	if cfg.Algo == "" && !cfg.DryRun {
 		fmt.Fprintln(os.Stderr, "Error: --algo is required")
 		fmt.Fprintln(os.Stderr, "Use --list to see available algorithms")
 		os.Exit(1)
 	}
 
 	// Parse algorithms
-	hashers, err := hasher.Parse(cfg.Algo)
+	var hashers []hasher.Hasher
+	var err error
+	if cfg.Algo != "" {
+		hashers, err = hasher.Parse(cfg.Algo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Apply output encodings (before HMAC, so per-algorithm names match)
+	if cfg.Encoding != "" {
+		hashers, err = hasher.ApplyEncodings(hashers, cfg.Encoding)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Wrap algorithms with HMAC if a key source is given
+	keyed := cfg.HMACKeyFile != "" || cfg.HMACKeyEnv != ""
+	if keyed {
+		key, err := readHMACKey(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		hashers, err = hasher.Keyed(hashers, key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Hash only a sample of each file, labeling the algorithms accordingly
+	sample, err := parseSample(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if sample != nil {
+		if cfg.CDC || cfg.ChunkSize != "" {
+			fmt.Fprintln(os.Stderr, "Error: sampling cannot be combined with --cdc or --chunk-size")
+			os.Exit(1)
+		}
+		hashers, err = hasher.Sampled(hashers, *sample)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Create scanner
-	s := scanner.NewScanner(hashers)
+	s, err := newScanner(cfg, hashers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	s.Workers = cfg.Workers
-	s.Recursive = cfg.Recursive
 	s.AbsolutePath = cfg.AbsolutePath
-
-	// Set error strategy
-	if cfg.OnError == "fail" {
-		s.OnError = scanner.FailOnError
-	} else {
-		s.OnError = scanner.SkipOnError
+	s.Sample = sample
+	s.Order, err = scanner.ParseReadOrder(cfg.Order)
+	if err == nil {
+		err = s.Order.Validate()
 	}
-
-	// Set filter options
-	filter, err := parseFilterOptions(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	s.Filter = filter
+	if (s.Symlinks == scanner.SkipSymlinks || s.Symlinks == scanner.RecordSymlinks) && cfg.Check != "" {
+		fmt.Fprintln(os.Stderr, "Error: --check always follows symlinks to the listed files")
+		os.Exit(1)
+	}
+	s.Hardlinks = cfg.Hardlinks
+	if cfg.PerDevice {
+		if cfg.HDDWorkers < 0 || cfg.SSDWorkers < 0 {
+			fmt.Fprintln(os.Stderr, "Error: per-device worker counts must not be negative")
+			os.Exit(1)
+		}
+		s.Devices = &scanner.DeviceLimits{Rotational: cfg.HDDWorkers, NonRotational: cfg.SSDWorkers}
+	}
+	s.IO.Mmap = cfg.Mmap
+	s.IO.DropCache = cfg.DropCache
+	s.IO.Direct = cfg.Direct
+	if cfg.BufferSize != "" {
+		size, err := parseSize(cfg.BufferSize)
+		if err != nil || size <= 0 || size > hasher.MaxBufferSize {
+			fmt.Fprintf(os.Stderr, "Error: invalid buffer-size: %s\n", cfg.BufferSize)
+			os.Exit(1)
+		}
+		s.IO.BufferSize = int(size)
+	}
+	if err := s.IO.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Throttle reads; the limits can be changed through the control socket
+	if cfg.LimitRate != "" || cfg.LimitFiles != "" || cfg.ControlSocket != "" {
+		rate, err := parseSize(cfg.LimitRate)
+		if err != nil || rate < 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid limit-rate: %s\n", cfg.LimitRate)
+			os.Exit(1)
+		}
+		var files float64
+		if cfg.LimitFiles != "" {
+			if files, err = parseFileRate(cfg.LimitFiles); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid limit-files: %s\n", cfg.LimitFiles)
+				os.Exit(1)
+			}
+		}
+		s.IO.Limiter = ratelimit.New(float64(rate), files)
+	}
+
+	if cfg.ChunkSize != "" {
+		s.ChunkSize, err = parseSize(cfg.ChunkSize)
+		if err != nil || s.ChunkSize <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid chunk-size: %s\n", cfg.ChunkSize)
+			os.Exit(1)
+		}
+	}
+
+	var cdcSummary *output.CDCSummary
+	if cfg.CDC {
+		if s.ChunkSize > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --cdc cannot be combined with --chunk-size")
+			os.Exit(1)
+		}
+		opts, err := parseCDCOptions(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		s.CDC = &opts
+
+		// Chunks are identified by the first algorithm that hashes them
+		for _, h := range hashers {
+			if _, fuzzy := h.(hasher.FuzzyHasher); !fuzzy {
+				cdcSummary = output.NewCDCSummary(h.Name())
+				break
+			}
+		}
+		if cdcSummary == nil {
+			fmt.Fprintln(os.Stderr, "Error: --cdc requires a non-fuzzy algorithm")
+			os.Exit(1)
+		}
+	}
+
+	algoNames := make([]string, len(hashers))
+	for i, h := range hashers {
+		algoNames[i] = h.Name()
+	}
 
 	// Create formatter
 	var formatter output.Formatter
-	if cfg.JSON {
-		formatter = output.NewJSONFormatter()
+	if cfg.SRI {
+		for _, name := range algoNames {
+			if !output.IsSRIAlgorithm(name) {
+				fmt.Fprintf(os.Stderr, "Error: algorithm %s cannot be used with --sri (use %s)\n",
+					name, strings.Join(output.SRIAlgorithms, ", "))
+				os.Exit(1)
+			}
+		}
+		if cfg.JSON {
+			formatter = output.NewSRIMapFormatter(algoNames, sriBase(cfg))
+		} else {
+			formatter = output.NewSRIFormatter(algoNames)
+		}
+	} else if cfg.JSON {
+		json := output.NewJSONFormatter()
+		json.ChunkSize = s.ChunkSize
+		formatter = json
 	} else {
-		algoNames := make([]string, len(hashers))
-		for i, h := range hashers {
-			algoNames[i] = h.Name()
+		text := output.NewTextFormatter(algoNames)
+		text.Labeled = keyed || sample != nil
+		formatter = text
+	}
+
+	// The control socket is removed on exit
+	exit := os.Exit
+	if cfg.ControlSocket != "" {
+		control, err := startControl(cfg.ControlSocket, s.IO.Limiter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer control.Close()
+		exit = func(code int) {
+			control.Close()
+			os.Exit(code)
+		}
+	}
+
+	if cfg.Explain && !cfg.DryRun {
+		fmt.Fprintln(os.Stderr, "Error: --explain requires --dry-run")
+		os.Exit(1)
+	}
+	if cfg.DryRun {
+		if cfg.Check != "" || cfg.Tree || cfg.Compare {
+			fmt.Fprintln(os.Stderr, "Error: --dry-run cannot be combined with --check, --tree or --compare")
+			os.Exit(1)
+		}
+		exit(runDryRun(cfg, s))
+	}
+
+	if cfg.Check != "" {
+		if cfg.Tree || cfg.SRI || cfg.Compare {
+			fmt.Fprintln(os.Stderr, "Error: --check cannot be combined with --tree, --sri or --compare")
+			os.Exit(1)
 		}
-		formatter = output.NewTextFormatter(algoNames)
+		exit(runCheck(cfg, s, hashers, formatter))
+	}
+
+	if cfg.Tree {
+		if cfg.SRI || cfg.Compare {
+			fmt.Fprintln(os.Stderr, "Error: --tree cannot be combined with --sri or --compare")
+			os.Exit(1)
+		}
+		exit(runTree(cfg, s, hashers, formatter))
 	}
 
 	// Determine input source and process
+	results := scanInputs(cfg, s)
+
+	if cfg.Compare {
+		exit(runCompare(cfg, hashers, results))
+	}
+
+	// Output results. Buffering formatters (the SRI map) emit a single
+	// document at the end, so their errors always go to stderr.
+	finisher, buffered := formatter.(output.Finisher)
+	var linkReport *output.HardlinkReport
+	if cfg.LinkReport {
+		linkReport = output.NewHardlinkReport()
+	}
+	hasError := false
+	for result := range results {
+		if result.IsError() {
+			hasError = true
+			if !cfg.Machine || buffered {
+				fmt.Fprintln(os.Stderr, formatter.FormatError(result))
+			} else if cfg.JSON {
+				fmt.Println(formatter.FormatError(result))
+			}
+		} else if line := formatter.Format(result); line != "" {
+			fmt.Println(line)
+		}
+		if cdcSummary != nil && !result.IsError() {
+			cdcSummary.Add(result)
+		}
+		if linkReport != nil && !result.IsError() {
+			linkReport.Add(result)
+		}
+	}
+	if buffered {
+		fmt.Println(finisher.Finish())
+	}
+	if cdcSummary != nil {
+		if buffered {
+			fmt.Fprintln(os.Stderr, cdcSummary.Format(false))
+		} else {
+			fmt.Println(cdcSummary.Format(cfg.JSON))
+		}
+	}
+	if linkReport != nil {
+		if buffered {
+			fmt.Fprintln(os.Stderr, linkReport.Format(false))
+		} else {
+			fmt.Println(linkReport.Format(cfg.JSON))
+		}
+	}
+
+	if hasError && s.OnError == scanner.FailOnError {
+		exit(1)
+	}
+}
+
+// sriBase returns the directory SRI map keys are made relative to: the
+// scanned directory when exactly one directory is given, otherwise "".
+func sriBase(cfg *Config) string {
+	if cfg.FromStdin || cfg.FromFile != "" || len(cfg.Paths) != 1 || cfg.AbsolutePath {
+		return ""
+	}
+	info, err := os.Stat(cfg.Paths[0])
+	if err != nil || !info.IsDir() {
+		return ""
+	}
+	return cfg.Paths[0]
+}
+
+// scanInputs starts scanning the configured input source (stdin, a path list
+// file or command line paths) and returns the merged results channel.
+func scanInputs(cfg *Config, s *scanner.Scanner) <-chan *scanner.Result {
 	var results <-chan *scanner.Result
 
 	if cfg.FromStdin {
@@ -178,24 +528,7 @@ func main() {
 		results = mergeResultChannels(resultChans)
 	}
 
-	// Output results
-	hasError := false
-	for result := range results {
-		if result.IsError() {
-			hasError = true
-			if !cfg.Machine {
-				fmt.Fprintln(os.Stderr, formatter.FormatError(result))
-			} else if cfg.JSON {
-				fmt.Println(formatter.FormatError(result))
-			}
-		} else {
-			fmt.Println(formatter.Format(result))
-		}
-	}
-
-	if hasError && s.OnError == scanner.FailOnError {
-		os.Exit(1)
-	}
+	return results
 }
 
 func parseFlags() *Config {
@@ -210,11 +543,17 @@ func parseFlags() *Config {
 
 	flag.BoolVar(&cfg.Recursive, "recursive", true, "Scan directories recursively")
 	flag.BoolVar(&cfg.Recursive, "r", true, "Scan directories recursively (shorthand)")
+	flag.IntVar(&cfg.MaxDepth, "max-depth", 0, "Scan at most this many directory levels; 1 = only the files in each directory (0 = no limit)")
+	flag.IntVar(&cfg.MinDepth, "min-depth", 0, "Skip files less than this many levels deep; 2 = skip the files directly in each directory")
+	flag.StringVar(&cfg.ExcludeDir, "exclude-dir", "", "Do not enter directories with these names, globs such as .cache,node_modules,*.tmp (comma-separated)")
+	flag.BoolVar(&cfg.SkipHiddenDirs, "skip-hidden-dirs", false, "Do not enter hidden directories")
 
 	flag.BoolVar(&cfg.Machine, "machine", false, "Machine-readable output (no progress)")
 	flag.BoolVar(&cfg.Machine, "m", false, "Machine-readable output (shorthand)")
 	flag.BoolVar(&cfg.JSON, "json", false, "Output as JSON Lines")
 	flag.BoolVar(&cfg.JSON, "j", false, "Output as JSON Lines (shorthand)")
+	flag.StringVar(&cfg.Encoding, "encoding", "", "Output encoding: hex, HEX, base64, base64url, base32, multibase; per algorithm as algo=enc (comma-separated)")
+	flag.BoolVar(&cfg.SRI, "sri", false, "Output Subresource Integrity strings (sha256/sha384/sha512, default sha384); with --json, a path -> integrity map")
 	flag.BoolVar(&cfg.AbsolutePath, "absolute", false, "Output absolute paths")
 
 	flag.StringVar(&cfg.OnError, "on-error", "skip", "Error handling: skip or fail")
@@ -225,13 +564,83 @@ func parseFlags() *Config {
 	flag.StringVar(&cfg.IncludeExt, "I", "", "Only process files with these extensions (shorthand)")
 	flag.StringVar(&cfg.ExcludeExt, "exclude-ext", "", "Skip files with these extensions (comma-separated)")
 	flag.StringVar(&cfg.ExcludeExt, "E", "", "Skip files with these extensions (shorthand)")
-	flag.StringVar(&cfg.Include, "include", "", "Include glob patterns (comma-separated)")
+	flag.StringVar(&cfg.Symlinks, "symlinks", "files", "Symlink handling: files (follow links to files only), follow (also descend into linked directories), skip or record (output the link target)")
+	flag.BoolVar(&cfg.Hardlinks, "hardlinks", true, "Hash hardlinked files once and reuse the hashes for the other links (Linux only)")
+	flag.BoolVar(&cfg.LinkReport, "report-hardlinks", false, "Report hardlink groups after the results")
+	flag.BoolVar(&cfg.BlockDevs, "block-devices", false, "Hash block devices (disk images) instead of skipping them")
+	flag.BoolVar(&cfg.OneFS, "one-file-system", false, "Do not descend into other filesystems than that of each directory (Linux only)")
+	flag.BoolVar(&cfg.OneFS, "x", false, "Do not descend into other filesystems (shorthand)")
+	flag.StringVar(&cfg.ExcludeFS, "exclude-fs", "", "Skip these filesystem types, e.g. proc,sysfs,tmpfs,nfs,fuse (comma-separated, Linux only)")
+	flag.StringVar(&cfg.Include, "include", "", "Include glob patterns, with ** and {a,b} (comma-separated)")
 	flag.StringVar(&cfg.Include, "i", "", "Include glob patterns (shorthand)")
-	flag.StringVar(&cfg.Exclude, "exclude", "", "Exclude glob patterns (comma-separated)")
+	flag.StringVar(&cfg.Exclude, "exclude", "", "Exclude glob patterns; matching directories are not walked (comma-separated)")
 	flag.StringVar(&cfg.Exclude, "e", "", "Exclude glob patterns (shorthand)")
+	flag.BoolVar(&cfg.IgnoreCase, "ignore-case", false, "Match glob patterns case-insensitively")
+	flag.BoolVar(&cfg.GitIgnore, "gitignore", false, "Skip files ignored by .gitignore files found while walking, and .git directories")
+	flag.StringVar(&cfg.IgnoreFile, "ignore-file", ".fhashignore", "Project ignore file in .gitignore syntax, relative to each scanned directory")
+	flag.BoolVar(&cfg.NoIgnore, "no-ignore", false, "Do not read any ignore files")
+	flag.Func("include-regex", "Only process files whose path matches this regexp (repeatable)", func(s string) error {
+		cfg.IncludeRegex = append(cfg.IncludeRegex, s)
+		return nil
+	})
+	flag.Func("exclude-regex", "Skip files whose path matches this regexp (repeatable)", func(s string) error {
+		cfg.ExcludeRegex = append(cfg.ExcludeRegex, s)
+		return nil
+	})
+	flag.StringVar(&cfg.NewerThan, "newer-than", "", "Only process files modified at or after this time: a date (2024-01-31), RFC 3339 time or age (7d, 12h)")
+	flag.StringVar(&cfg.OlderThan, "older-than", "", "Only process files modified before this time or age")
+	flag.StringVar(&cfg.ChangedNewer, "changed-newer-than", "", "Only process files whose status changed (ctime) at or after this time or age (Linux only)")
+	flag.StringVar(&cfg.ChangedOlder, "changed-older-than", "", "Only process files whose status changed (ctime) before this time or age (Linux only)")
+	flag.StringVar(&cfg.Owner, "owner", "", "Only process files owned by these users, names or UIDs (comma-separated, Linux only)")
+	flag.StringVar(&cfg.Group, "group", "", "Only process files owned by these groups, names or GIDs (comma-separated, Linux only)")
+	flag.StringVar(&cfg.Perm, "perm", "", "Only process files with these octal permission bits: 644 exactly, -111 all of them, /022 any of them")
+	flag.BoolVar(&cfg.SkipHidden, "skip-hidden", false, "Skip hidden files (dot files, and files with the hidden attribute on Windows)")
+	flag.StringVar(&cfg.Filter, "filter", "", "Filter expression, e.g. '(ext in [.jpg,.png] and size > 1MB) or path ~ \"^raw/\"'")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "List the files that would be hashed and their sizes without reading them, with totals")
+	flag.BoolVar(&cfg.Explain, "explain", false, "With --dry-run, also list the files and directories left out and why")
 
 	flag.IntVar(&cfg.Workers, "workers", runtime.NumCPU(), "Number of concurrent workers")
 	flag.IntVar(&cfg.Workers, "w", runtime.NumCPU(), "Number of concurrent workers (shorthand)")
+	flag.BoolVar(&cfg.PerDevice, "per-device", false, "Also limit workers per storage device (Linux only)")
+	flag.IntVar(&cfg.HDDWorkers, "hdd-workers", scanner.DefaultDeviceLimits().Rotational, "Workers per spinning disk (with --per-device, 0 = no limit)")
+	flag.IntVar(&cfg.SSDWorkers, "ssd-workers", scanner.DefaultDeviceLimits().NonRotational, "Workers per SSD (with --per-device, 0 = no limit)")
+	flag.StringVar(&cfg.Order, "order", "walk", "Read order: walk, inode or physical (disk layout via FIEMAP, Linux amd64/arm64 only)")
+	flag.StringVar(&cfg.BufferSize, "buffer-size", "", "Read buffer size per worker (default: 256KB)")
+	flag.BoolVar(&cfg.Mmap, "mmap", false, "Memory-map large files instead of reading them (Linux amd64/arm64 only)")
+	flag.BoolVar(&cfg.DropCache, "drop-cache", false, "Drop hashed files from the page cache (Linux amd64/arm64 only)")
+	flag.BoolVar(&cfg.Direct, "direct", false, "Read files with O_DIRECT, bypassing the page cache (Linux amd64/arm64 only)")
+	flag.StringVar(&cfg.LimitRate, "limit-rate", "", "Limit read bandwidth per second across all workers (e.g., 50MB)")
+	flag.StringVar(&cfg.LimitFiles, "limit-files", "", "Limit files opened per second across all workers")
+	flag.StringVar(&cfg.ControlSocket, "control-socket", "", "Unix socket to change the limits while running")
+
+	flag.StringVar(&cfg.Torrent, "torrent", "", "BitTorrent mode: v1, v2 or hybrid (prints infohash instead of file hashes)")
+	flag.StringVar(&cfg.TorrentOut, "torrent-out", "", "Write the .torrent metainfo to this file (torrent mode)")
+	flag.StringVar(&cfg.PieceLength, "piece-length", "", "Torrent piece length, power of two >= 16KB (default: auto)")
+
+	flag.StringVar(&cfg.HMACKeyFile, "hmac-key-file", "", "Compute HMAC with the key read from this file (- for stdin)")
+	flag.StringVar(&cfg.HMACKeyEnv, "hmac-key-env", "", "Compute HMAC with the key read from this environment variable")
+
+	flag.StringVar(&cfg.ChunkSize, "chunk-size", "", "Also hash each block of this size (e.g., 4MB), output as chunk lists in JSON")
+	flag.StringVar(&cfg.Offset, "offset", "", "Hash only the bytes from this offset (labeled as a range hash)")
+	flag.StringVar(&cfg.Length, "length", "", "Hash only this many bytes (with --offset; default: to the end)")
+	flag.StringVar(&cfg.HeadTail, "head-tail", "", "Hash only the first and last N bytes plus the file size (e.g., 64KB)")
+	flag.IntVar(&cfg.Samples, "samples", 0, "Hash only K evenly spaced samples plus the file size")
+	flag.StringVar(&cfg.SampleSize, "sample-size", "64KB", "Size of each sample (with --samples)")
+
+	flag.BoolVar(&cfg.CDC, "cdc", false, "Also hash content-defined chunks (FastCDC) and print a deduplication summary")
+	flag.StringVar(&cfg.CDCMin, "cdc-min", "", "Minimum CDC chunk size (default: avg/4)")
+	flag.StringVar(&cfg.CDCAvg, "cdc-avg", "64KB", "Average CDC chunk size")
+	flag.StringVar(&cfg.CDCMax, "cdc-max", "", "Maximum CDC chunk size (default: avg*4)")
+	flag.StringVar(&cfg.Check, "check", "", "Verify the files listed in this manifest (fhash text or JSON output)")
+	flag.StringVar(&cfg.Check, "c", "", "Verify the files listed in this manifest (shorthand)")
+
+	flag.BoolVar(&cfg.Tree, "tree", false, "Print a Merkle tree hash of each directory instead of file hashes")
+	flag.BoolVar(&cfg.TreeDirs, "tree-dirs", false, "Also print the digest of every subdirectory (tree mode)")
+	flag.BoolVar(&cfg.TreeModes, "tree-modes", false, "Include the executable bit of files in the tree hash (tree mode)")
+
+	flag.BoolVar(&cfg.Compare, "compare", false, "Compare fuzzy hashes (ssdeep, tlsh) between files and report similarity")
+	flag.StringVar(&cfg.Manifest, "manifest", "", "Compare against the hashes in this manifest (fhash text or JSON output)")
+	flag.IntVar(&cfg.Threshold, "threshold", -1, "Only report ssdeep scores >= N or tlsh distances <= N (compare mode)")
 
 	flag.BoolVar(&cfg.ListAlgos, "list", false, "List supported algorithms")
 	flag.BoolVar(&cfg.ListAlgos, "l", false, "List supported algorithms (shorthand)")
@@ -247,18 +656,102 @@ func parseFlags() *Config {
 		fmt.Fprintln(os.Stderr, "  fhash -a md5,sha256 ./dist")
 		fmt.Fprintln(os.Stderr, "  fhash -a sha256 -m -j ./dist")
 		fmt.Fprintln(os.Stderr, "  fhash -a xxh3 --max-size 100MB -E .log,.tmp ./project")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --gitignore ./repo")
+		fmt.Fprintln(os.Stderr, "  fhash -a xxh3 --max-depth 2 --exclude-dir .cache,node_modules --skip-hidden-dirs ~")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --newer-than 7d --owner 1000 --skip-hidden /srv/data")
+		fmt.Fprintln(os.Stderr, "  fhash --dry-run --filter '(ext in [.jpg,.png] and size > 1MB) or path ~ \"^raw/\"' ./photos")
+		fmt.Fprintln(os.Stderr, "  fhash --dry-run --explain --gitignore --max-size 100MB ./repo")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 -i \"src/**/*.{go,mod}\" -e \"**/testdata/**\" ./repo")
 		fmt.Fprintln(os.Stderr, "  cat files.txt | fhash -a sha256 --from-stdin -m -j")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256,md5 --encoding base64,md5=HEX ./dist")
+		fmt.Fprintln(os.Stderr, "  fhash --sri -j ./dist > sri.json")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --hmac-key-env FHASH_KEY ./dist")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --chunk-size 4MB -j ./backup > backup.json")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --check backup.json")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --cdc --cdc-avg 1MB -j ./backups")
+		fmt.Fprintln(os.Stderr, "  fhash -a xxh3 --head-tail 1MB ./videos")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --direct -c backup.txt")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --per-device --order physical /mnt/hdd /mnt/ssd")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --limit-rate 50MB --control-socket /tmp/fhash.sock /srv")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --symlinks record -j ./rootfs")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --block-devices /dev/loop0")
+		fmt.Fprintln(os.Stderr, "  fhash -a xxh3 -x --exclude-fs proc,sysfs,tmpfs,nfs,fuse /")
+		fmt.Fprintln(os.Stderr, "  fhash -a sha256 --tree --tree-dirs ./dist")
+		fmt.Fprintln(os.Stderr, "  fhash -a ssdeep,tlsh --compare --manifest known.txt ./samples")
+		fmt.Fprintln(os.Stderr, "  fhash --torrent hybrid --torrent-out dist.torrent ./dist")
 		fmt.Fprintln(os.Stderr)
 		fmt.Fprintln(os.Stderr, "Options:")
 		flag.PrintDefaults()
 	}
 
 	flag.Parse()
+
+	// Setting a per-device limit implies per-device scheduling
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "hdd-workers" || f.Name == "ssd-workers" {
+			cfg.PerDevice = true
+		}
+	})
 	cfg.Paths = flag.Args()
 
 	return cfg
 }
 
+// newScanner creates a scanner for hashers with the settings that decide
+// which files are scanned: recursion, symlinks, filesystems, filters and
+// ignore files, and the error strategy. Every mode that walks directories
+// builds its scanner here.
+func newScanner(cfg *Config, hashers []hasher.Hasher) (*scanner.Scanner, error) {
+	s := scanner.NewScanner(hashers)
+	if err := applyWalkOptions(cfg, s); err != nil {
+		return nil, err
+	}
+
+	var err error
+	s.Symlinks, err = scanner.ParseSymlinkPolicy(cfg.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	s.BlockDevices = cfg.BlockDevs
+	s.OneFileSystem = cfg.OneFS
+	if cfg.ExcludeFS != "" {
+		s.ExcludeFSTypes = splitAndTrim(cfg.ExcludeFS)
+	}
+
+	// Set error strategy
+	if cfg.OnError == "fail" {
+		s.OnError = scanner.FailOnError
+	} else {
+		s.OnError = scanner.SkipOnError
+	}
+
+	// Set filter options
+	s.Filter, err = parseFilterOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// applyWalkOptions sets the scanner's recursion, depth and directory
+// exclusion settings.
+func applyWalkOptions(cfg *Config, s *scanner.Scanner) error {
+	if cfg.MaxDepth < 0 || cfg.MinDepth < 0 {
+		return fmt.Errorf("depths cannot be negative")
+	}
+	if cfg.MaxDepth > 0 && cfg.MinDepth > cfg.MaxDepth {
+		return fmt.Errorf("min-depth %d is greater than max-depth %d", cfg.MinDepth, cfg.MaxDepth)
+	}
+	s.Recursive = cfg.Recursive
+	s.MaxDepth = cfg.MaxDepth
+	s.MinDepth = cfg.MinDepth
+	if cfg.ExcludeDir != "" {
+		s.ExcludeDirNames = splitPatterns(cfg.ExcludeDir)
+	}
+	s.SkipHiddenDirs = cfg.SkipHiddenDirs
+	return nil
+}
+
 func parseFilterOptions(cfg *Config) (*scanner.FilterOptions, error) {
 	filter := &scanner.FilterOptions{}
 
@@ -288,15 +781,234 @@ func parseFilterOptions(cfg *Config) (*scanner.FilterOptions, error) {
 
 	// Parse globs
 	if cfg.Include != "" {
-		filter.IncludeGlobs = splitAndTrim(cfg.Include)
+		filter.IncludeGlobs = splitPatterns(cfg.Include)
 	}
 	if cfg.Exclude != "" {
-		filter.ExcludeGlobs = splitAndTrim(cfg.Exclude)
+		filter.ExcludeGlobs = splitPatterns(cfg.Exclude)
+	}
+	filter.IgnoreCase = cfg.IgnoreCase
+
+	// Ignore files
+	if !cfg.NoIgnore {
+		filter.GitIgnore = cfg.GitIgnore
+		filter.IgnoreFile = cfg.IgnoreFile
+	}
+
+	// Parse regexps
+	var err error
+	if filter.IncludeRegexps, err = compileRegexps(cfg.IncludeRegex); err != nil {
+		return nil, fmt.Errorf("invalid include-regex: %w", err)
+	}
+	if filter.ExcludeRegexps, err = compileRegexps(cfg.ExcludeRegex); err != nil {
+		return nil, fmt.Errorf("invalid exclude-regex: %w", err)
+	}
+
+	// Parse times, relative to the same instant
+	now := time.Now()
+	times := []struct {
+		name  string
+		value string
+		dst   *time.Time
+	}{
+		{"newer-than", cfg.NewerThan, &filter.ModifiedAfter},
+		{"older-than", cfg.OlderThan, &filter.ModifiedBefore},
+		{"changed-newer-than", cfg.ChangedNewer, &filter.ChangedAfter},
+		{"changed-older-than", cfg.ChangedOlder, &filter.ChangedBefore},
+	}
+	for _, t := range times {
+		if t.value == "" {
+			continue
+		}
+		if *t.dst, err = scanner.ParseTime(t.value, now); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", t.name, err)
+		}
+	}
+
+	// Parse owners
+	if runtime.GOOS != "linux" && (cfg.Owner != "" || cfg.Group != "" || cfg.ChangedNewer != "" || cfg.ChangedOlder != "") {
+		return nil, fmt.Errorf("owner, group and ctime filters are only supported on Linux")
+	}
+	if cfg.Owner != "" {
+		if filter.Owners, err = parseIDs(cfg.Owner, lookupUser); err != nil {
+			return nil, fmt.Errorf("invalid owner: %w", err)
+		}
+	}
+	if cfg.Group != "" {
+		if filter.Groups, err = parseIDs(cfg.Group, lookupGroup); err != nil {
+			return nil, fmt.Errorf("invalid group: %w", err)
+		}
+	}
+
+	if cfg.Perm != "" {
+		if filter.Perm, err = scanner.ParsePermFilter(cfg.Perm); err != nil {
+			return nil, err
+		}
+	}
+	filter.SkipHidden = cfg.SkipHidden
+
+	if cfg.Filter != "" {
+		if filter.Expr, err = scanner.ParseExpr(cfg.Filter, now); err != nil {
+			// Show where the error is under the expression
+			var exprErr *scanner.ExprError
+			if errors.As(err, &exprErr) {
+				context := "  " + strings.ReplaceAll(exprErr.Context(), "\n", "\n  ")
+				return nil, fmt.Errorf("invalid filter: %w\n%s", err, context)
+			}
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
 	}
 
 	return filter, nil
 }
 
+func compileRegexps(patterns []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// parseIDs parses a comma-separated list of numeric IDs or names, looking up
+// names with lookup.
+func parseIDs(s string, lookup func(string) (string, error)) ([]uint32, error) {
+	var ids []uint32
+	for _, name := range splitAndTrim(s) {
+		id, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			value, lookupErr := lookup(name)
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			if id, err = strconv.ParseUint(value, 10, 32); err != nil {
+				return nil, fmt.Errorf("%s has no numeric ID", name)
+			}
+		}
+		ids = append(ids, uint32(id))
+	}
+	return ids, nil
+}
+
+func lookupUser(name string) (string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return u.Uid, nil
+}
+
+func lookupGroup(name string) (string, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+	return g.Gid, nil
+}
+
+// parseSample parses the sampling options. It returns nil if the whole file
+// is to be hashed.
+func parseSample(cfg *Config) (*hasher.Sample, error) {
+	if cfg.Offset == "" && cfg.Length == "" && cfg.HeadTail == "" && cfg.Samples == 0 {
+		return nil, nil
+	}
+
+	sample := &hasher.Sample{Count: cfg.Samples}
+	sizes := []struct {
+		name  string
+		value string
+		dst   *int64
+	}{
+		{"offset", cfg.Offset, &sample.Offset},
+		{"length", cfg.Length, &sample.Length},
+		{"head-tail", cfg.HeadTail, &sample.HeadTail},
+		{"sample-size", cfg.SampleSize, &sample.SampleSize},
+	}
+	for _, size := range sizes {
+		if size.value == "" {
+			continue
+		}
+		v, err := parseSize(size.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", size.name, err)
+		}
+		*size.dst = v
+	}
+	if cfg.HeadTail != "" && sample.HeadTail <= 0 {
+		return nil, fmt.Errorf("head-tail size must be positive")
+	}
+
+	return sample, sample.Validate()
+}
+
+// parseCDCOptions parses the FastCDC chunk sizes. Unset minimum and maximum
+// sizes default to a quarter and four times the average.
+func parseCDCOptions(cfg *Config) (fastcdc.Options, error) {
+	avg, err := parseSize(cfg.CDCAvg)
+	if err != nil {
+		return fastcdc.Options{}, fmt.Errorf("invalid cdc-avg: %w", err)
+	}
+	opts := fastcdc.DefaultOptions(int(avg))
+
+	if cfg.CDCMin != "" {
+		size, err := parseSize(cfg.CDCMin)
+		if err != nil {
+			return fastcdc.Options{}, fmt.Errorf("invalid cdc-min: %w", err)
+		}
+		opts.MinSize = int(size)
+	}
+	if cfg.CDCMax != "" {
+		size, err := parseSize(cfg.CDCMax)
+		if err != nil {
+			return fastcdc.Options{}, fmt.Errorf("invalid cdc-max: %w", err)
+		}
+		opts.MaxSize = int(size)
+	}
+
+	return opts, opts.Validate()
+}
+
+// readHMACKey reads the HMAC key from the configured file, stdin or
+// environment variable. A single trailing newline is removed from keys read
+// from a file or stdin.
+func readHMACKey(cfg *Config) ([]byte, error) {
+	if cfg.HMACKeyFile != "" && cfg.HMACKeyEnv != "" {
+		return nil, fmt.Errorf("only one of --hmac-key-file and --hmac-key-env may be given")
+	}
+
+	if cfg.HMACKeyEnv != "" {
+		key, ok := os.LookupEnv(cfg.HMACKeyEnv)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("environment variable %s is not set or empty", cfg.HMACKeyEnv)
+		}
+		return []byte(key), nil
+	}
+
+	var key []byte
+	var err error
+	if cfg.HMACKeyFile == "-" {
+		if cfg.FromStdin {
+			return nil, fmt.Errorf("cannot read both the HMAC key and file paths from stdin")
+		}
+		key, err = io.ReadAll(os.Stdin)
+	} else {
+		key, err = os.ReadFile(cfg.HMACKeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HMAC key: %w", err)
+	}
+
+	key = bytes.TrimSuffix(key, []byte("\n"))
+	key = bytes.TrimSuffix(key, []byte("\r"))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("HMAC key is empty")
+	}
+	return key, nil
+}
+
 func parseSize(s string) (int64, error) {
 	s = strings.TrimSpace(strings.ToUpper(s))
 	if s == "" {
@@ -341,6 +1053,36 @@ func splitAndTrim(s string) []string {
 	return result
 }
 
+// splitPatterns splits a comma-separated list of glob patterns, keeping the
+// commas inside brace groups such as "*.{go,mod}".
+func splitPatterns(s string) []string {
+	var result []string
+	depth, last := 0, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch s[i] {
+			case '{':
+				depth++
+				continue
+			case '}':
+				depth = max(depth-1, 0)
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if p := strings.TrimSpace(s[last:i]); p != "" {
+			result = append(result, p)
+		}
+		last = i + 1
+	}
+	return result
+}
+
 // mergeResultChannels merges multiple result channels into one.
 func mergeResultChannels(chans []<-chan *scanner.Result) <-chan *scanner.Result {
 	out := make(chan *scanner.Result)
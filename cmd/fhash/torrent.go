@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Virace/fast-hasher/internal/scanner"
+	"github.com/Virace/fast-hasher/internal/torrent"
+)
+
+// runTorrent builds a BitTorrent info dictionary for a single file or directory,
+// prints its infohash(es) and optionally writes a .torrent file.
+// It returns the process exit code.
+func runTorrent(cfg *Config) int {
+	version, err := torrent.ParseVersion(cfg.Torrent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if len(cfg.Paths) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: torrent mode requires exactly one file or directory")
+		return 1
+	}
+	root := cfg.Paths[0]
+
+	opts := torrent.Options{Version: version}
+	if cfg.PieceLength != "" {
+		opts.PieceLength, err = parseSize(cfg.PieceLength)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid piece-length: %v\n", err)
+			return 1
+		}
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Use the scanner's walk so the file list is the one the other modes
+	// would hash
+	var paths []string
+	if info.IsDir() {
+		s, err := newScanner(cfg, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if s.Symlinks == scanner.RecordSymlinks {
+			fmt.Fprintln(os.Stderr, "Error: torrent mode cannot record symlinks (use --symlinks files, follow or skip)")
+			return 1
+		}
+		paths, err = s.ListFiles(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	t, err := torrent.Build(root, paths, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if cfg.TorrentOut != "" {
+		data, err := t.MarshalTorrent()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(cfg.TorrentOut, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if cfg.JSON {
+		data := map[string]interface{}{"path": root}
+		if t.InfoHashV1 != nil {
+			data["infohash_v1"] = hex.EncodeToString(t.InfoHashV1)
+		}
+		if t.InfoHashV2 != nil {
+			data["infohash_v2"] = hex.EncodeToString(t.InfoHashV2)
+		}
+		if cfg.TorrentOut != "" {
+			data["torrent"] = cfg.TorrentOut
+		}
+		b, _ := json.Marshal(data)
+		fmt.Println(string(b))
+		return 0
+	}
+
+	if t.InfoHashV1 != nil {
+		fmt.Printf("infohash-v1:%s  %s\n", hex.EncodeToString(t.InfoHashV1), root)
+	}
+	if t.InfoHashV2 != nil {
+		fmt.Printf("infohash-v2:%s  %s\n", hex.EncodeToString(t.InfoHashV2), root)
+	}
+	return 0
+}
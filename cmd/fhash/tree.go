@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+	"github.com/Virace/fast-hasher/internal/output"
+	"github.com/Virace/fast-hasher/internal/scanner"
+	"github.com/Virace/fast-hasher/internal/tree"
+)
+
+// runTree scans each directory argument and prints its Merkle tree root,
+// plus every subdirectory digest with --tree-dirs. Any file error fails the
+// tree, since a partial fingerprint could match a different directory.
+// It returns the process exit code.
+func runTree(cfg *Config, s *scanner.Scanner, hashers []hasher.Hasher, formatter output.Formatter) int {
+	if cfg.FromStdin || cfg.FromFile != "" || len(cfg.Paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: tree mode requires one or more directories as arguments")
+		return 1
+	}
+
+	// Paths are made relative to the scanned directory, so scan with the
+	// paths as given and only apply --absolute to the printed root.
+	s.AbsolutePath = false
+
+	exitCode := 0
+	for _, dir := range cfg.Paths {
+		info, err := os.Stat(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitCode = 1
+			continue
+		}
+		if !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", dir)
+			exitCode = 1
+			continue
+		}
+
+		root, err := buildTree(cfg, s, hashers, formatter, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", dir, err)
+			exitCode = 1
+			continue
+		}
+
+		display := dir
+		if cfg.AbsolutePath {
+			if abs, err := filepath.Abs(dir); err == nil {
+				display = abs
+			}
+		}
+
+		root.Walk(func(n *tree.Node) {
+			if n != root && !cfg.TreeDirs {
+				return
+			}
+			result := &scanner.Result{
+				Path:   filepath.Join(display, filepath.FromSlash(n.Path)),
+				Size:   n.Size,
+				Hashes: make(map[string]string, len(hashers)),
+			}
+			for _, h := range hashers {
+				result.Hashes[h.Name()] = h.Encoding().Encode(n.Digests[h.Name()])
+			}
+			fmt.Println(formatter.Format(result))
+		})
+	}
+	return exitCode
+}
+
+// buildTree hashes every file under dir and combines the digests into a tree.
+func buildTree(cfg *Config, s *scanner.Scanner, hashers []hasher.Hasher, formatter output.Formatter, dir string) (*tree.Node, error) {
+	var files []tree.File
+	failed := false
+
+	for result := range s.ScanDir(dir) {
+		if result.IsError() {
+			fmt.Fprintln(os.Stderr, formatter.FormatError(result))
+			failed = true
+			continue
+		}
+		if result.Skipped {
+			continue // Special files are not part of the tree
+		}
+
+		rel, err := filepath.Rel(dir, result.Path)
+		if err != nil {
+			return nil, err
+		}
+		f := tree.File{
+			Path:    filepath.ToSlash(rel),
+			Size:    result.Size,
+			Mode:    result.Mode,
+			Digests: make(map[string][]byte, len(hashers)),
+		}
+		for _, h := range hashers {
+			if result.Type == scanner.TypeSymlink {
+				// Links are entered by their target, as git does
+				hh := h.New()
+				hh.Write([]byte(filepath.ToSlash(result.Target)))
+				f.Digests[h.Name()] = hh.Sum(nil)
+				continue
+			}
+			f.Digests[h.Name()], err = hasher.Decode(result.Hashes[h.Name()], h.OutputSize())
+			if err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, f)
+	}
+
+	if failed {
+		return nil, fmt.Errorf("some files could not be hashed")
+	}
+	return tree.Build(hashers, files, tree.Options{Modes: cfg.TreeModes})
+}
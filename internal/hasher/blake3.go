@@ -3,15 +3,32 @@ package hasher
 import (
 	"hash"
 
+	"github.com/Virace/fast-hasher/pkg/blake3tree"
 	"github.com/zeebo/blake3"
 )
 
+// blake3MinWorkers is roughly how many portable segment hashers it takes to
+// outrun the SIMD implementation used for sequential hashing.
+const blake3MinWorkers = 8
+
 type blake3Hasher struct{}
 
-func (blake3Hasher) Name() string    { return "blake3" }
-func (blake3Hasher) New() hash.Hash  { return blake3.New() }
-func (blake3Hasher) OutputSize() int { return 32 }
-func (blake3Hasher) IsBase64() bool  { return false }
+func (blake3Hasher) Name() string       { return "blake3" }
+func (blake3Hasher) New() hash.Hash     { return blake3.New() }
+func (blake3Hasher) OutputSize() int    { return 32 }
+func (blake3Hasher) Encoding() Encoding { return Hex }
+
+// Segments are complete BLAKE3 subtrees combined into the standard digest.
+func (blake3Hasher) SegmentSize() int64 { return parallelSegmentSize }
+func (blake3Hasher) MinWorkers() int    { return blake3MinWorkers }
+
+func (blake3Hasher) NewSegment(index int64) hash.Hash {
+	return blake3tree.NewSubtree(uint64(index * parallelSegmentSize / blake3tree.ChunkSize))
+}
+
+func (blake3Hasher) Combine(segments [][]byte, size int64) []byte {
+	return blake3tree.Root(segments)
+}
 
 func init() {
 	Register(blake3Hasher{})
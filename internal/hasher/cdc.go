@@ -0,0 +1,92 @@
+package hasher
+
+import (
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/Virace/fast-hasher/pkg/fastcdc"
+)
+
+// CDCChunk is a content-defined chunk of a file.
+type CDCChunk struct {
+	Offset int64
+	Length int
+	Hashes map[string]string // Algorithm name -> chunk hash value
+}
+
+// HashReaderCDC computes hashes like HashReader and also splits the data into
+// content-defined chunks with FastCDC, hashing each chunk with every
+// non-fuzzy hasher. Chunk digests use the same encoding as whole-file digests.
+func HashReaderCDC(r io.Reader, hashers []Hasher, opts fastcdc.Options) (map[string]string, []CDCChunk, error) {
+	if len(hashers) == 0 {
+		return nil, nil, fmt.Errorf("no hashers provided")
+	}
+
+	whole := make([]hash.Hash, len(hashers))
+	writers := make([]io.Writer, len(hashers))
+	var chunkHashers []Hasher
+	for i, h := range hashers {
+		whole[i] = h.New()
+		writers[i] = whole[i]
+		if _, ok := h.(FuzzyHasher); !ok {
+			chunkHashers = append(chunkHashers, h)
+		}
+	}
+	if len(chunkHashers) == 0 {
+		return nil, nil, fmt.Errorf("content-defined chunking requires a non-fuzzy algorithm")
+	}
+
+	// The chunker reads through the whole-file hashes
+	chunker, err := fastcdc.NewChunker(io.TeeReader(r, io.MultiWriter(writers...)), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := make([]hash.Hash, len(chunkHashers))
+	for i, h := range chunkHashers {
+		current[i] = h.New()
+	}
+
+	chunks := []CDCChunk{}
+	for {
+		c, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read data: %w", err)
+		}
+
+		chunk := CDCChunk{Offset: c.Offset, Length: c.Length, Hashes: make(map[string]string, len(chunkHashers))}
+		for i, h := range chunkHashers {
+			current[i].Reset()
+			current[i].Write(c.Data)
+			chunk.Hashes[h.Name()] = h.Encoding().Encode(current[i].Sum(nil))
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	results := make(map[string]string, len(hashers))
+	for i, h := range hashers {
+		sum := whole[i].Sum(nil)
+		if _, ok := h.(FuzzyHasher); ok {
+			results[h.Name()] = string(sum)
+		} else {
+			results[h.Name()] = h.Encoding().Encode(sum)
+		}
+	}
+	return results, chunks, nil
+}
+
+// HashFileCDC computes hashes and content-defined chunks for a file, reading
+// it as configured by ioOpts. See HashReaderCDC.
+func HashFileCDC(path string, hashers []Hasher, opts fastcdc.Options, ioOpts IOOptions) (map[string]string, []CDCChunk, error) {
+	f, err := openFile(path, ioOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return HashReaderCDC(f, hashers, opts)
+}
@@ -0,0 +1,28 @@
+package hasher
+
+import (
+	"hash"
+
+	"github.com/Virace/fast-hasher/pkg/ed2k"
+	"github.com/Virace/fast-hasher/pkg/md4"
+)
+
+type ed2kHasher struct{}
+
+func (ed2kHasher) Name() string       { return "ed2k" }
+func (ed2kHasher) New() hash.Hash     { return ed2k.New() }
+func (ed2kHasher) OutputSize() int    { return ed2k.Size }
+func (ed2kHasher) Encoding() Encoding { return Hex }
+
+// Segments are ED2K chunks, each hashed with MD4.
+func (ed2kHasher) SegmentSize() int64               { return ed2k.ChunkSize }
+func (ed2kHasher) MinWorkers() int                  { return 1 }
+func (ed2kHasher) NewSegment(index int64) hash.Hash { return md4.New() }
+
+func (ed2kHasher) Combine(segments [][]byte, size int64) []byte {
+	return ed2k.Combine(segments, size)
+}
+
+func init() {
+	Register(ed2kHasher{})
+}
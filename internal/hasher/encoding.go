@@ -0,0 +1,202 @@
+package hasher
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Encoding defines how a hash digest is rendered as text.
+type Encoding int
+
+const (
+	// Hex is lowercase hexadecimal (the default for most algorithms).
+	Hex Encoding = iota
+	// HexUpper is uppercase hexadecimal.
+	HexUpper
+	// Base64 is standard padded base64 (RFC 4648 section 4).
+	Base64
+	// Base64URL is unpadded URL-safe base64 (RFC 4648 section 5).
+	Base64URL
+	// Base32 is standard padded base32 (RFC 4648 section 6).
+	Base32
+	// Multibase is self-describing unpadded lowercase base32 with the
+	// multibase prefix "b".
+	Multibase
+)
+
+var encodingNames = map[Encoding]string{
+	Hex:       "hex",
+	HexUpper:  "HEX",
+	Base64:    "base64",
+	Base64URL: "base64url",
+	Base32:    "base32",
+	Multibase: "multibase",
+}
+
+// String returns the encoding name as accepted by ParseEncoding.
+func (e Encoding) String() string {
+	if name, ok := encodingNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// ParseEncoding parses an encoding name.
+// Accepted: hex, HEX (or upper-hex), base64, base64url, base32, multibase.
+func ParseEncoding(s string) (Encoding, error) {
+	switch strings.TrimSpace(s) {
+	case "hex":
+		return Hex, nil
+	case "HEX", "upper-hex", "hex-upper":
+		return HexUpper, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "base64", "b64":
+		return Base64, nil
+	case "base64url", "b64url":
+		return Base64URL, nil
+	case "base32", "b32":
+		return Base32, nil
+	case "multibase":
+		return Multibase, nil
+	}
+	return 0, fmt.Errorf("unknown encoding: %s (available: hex, HEX, base64, base64url, base32, multibase)", s)
+}
+
+var multibaseBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Encode renders a digest in this encoding.
+func (e Encoding) Encode(sum []byte) string {
+	switch e {
+	case HexUpper:
+		return strings.ToUpper(hex.EncodeToString(sum))
+	case Base64:
+		return base64.StdEncoding.EncodeToString(sum)
+	case Base64URL:
+		return base64.RawURLEncoding.EncodeToString(sum)
+	case Base32:
+		return base32.StdEncoding.EncodeToString(sum)
+	case Multibase:
+		return "b" + strings.ToLower(multibaseBase32.EncodeToString(sum))
+	default:
+		return hex.EncodeToString(sum)
+	}
+}
+
+// Decode parses a digest of the given size in bytes written in any supported
+// encoding: hex (either case), base64 or base64url (padded or not), base32
+// (padded or not, either case) or multibase (f/F, b/B, m, u prefixes).
+func Decode(s string, size int) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	decoders := []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+		func(s string) ([]byte, error) { return base32.StdEncoding.DecodeString(strings.ToUpper(s)) },
+		func(s string) ([]byte, error) { return multibaseBase32.DecodeString(strings.ToUpper(s)) },
+	}
+	for _, decode := range decoders {
+		if b, err := decode(s); err == nil && len(b) == size {
+			return b, nil
+		}
+	}
+
+	if len(s) > 1 {
+		var decode func(string) ([]byte, error)
+		switch s[0] {
+		case 'f', 'F':
+			decode = hex.DecodeString
+		case 'b', 'B':
+			decode = func(s string) ([]byte, error) { return multibaseBase32.DecodeString(strings.ToUpper(s)) }
+		case 'm':
+			decode = base64.RawStdEncoding.DecodeString
+		case 'u':
+			decode = base64.RawURLEncoding.DecodeString
+		}
+		if decode != nil {
+			if b, err := decode(s[1:]); err == nil && len(b) == size {
+				return b, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("cannot decode %q as a %d-byte digest", s, size)
+}
+
+// encodedHasher overrides the output encoding of a hasher.
+type encodedHasher struct {
+	Hasher
+	encoding Encoding
+}
+
+func (h encodedHasher) Encoding() Encoding { return h.encoding }
+
+// WithEncoding returns a hasher that renders its digests in the given encoding.
+// Fuzzy hashers produce text digests and cannot be re-encoded.
+func WithEncoding(h Hasher, e Encoding) (Hasher, error) {
+	if _, ok := h.(FuzzyHasher); ok {
+		return nil, fmt.Errorf("algorithm %s does not support output encodings", h.Name())
+	}
+	return encodedHasher{Hasher: h, encoding: e}, nil
+}
+
+// ApplyEncodings applies an encoding specification to hashers.
+// The spec is a comma-separated list where a bare encoding sets the default
+// for all algorithms and "algo=encoding" overrides a single algorithm.
+// Example: "base64,md5=hex". The default is not applied to fuzzy hashers.
+func ApplyEncodings(hashers []Hasher, spec string) ([]Hasher, error) {
+	var global *Encoding
+	perAlgo := make(map[string]Encoding)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, encName, found := strings.Cut(part, "=")
+		if !found {
+			e, err := ParseEncoding(part)
+			if err != nil {
+				return nil, err
+			}
+			global = &e
+			continue
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := Get(name); !ok {
+			return nil, fmt.Errorf("unknown algorithm in encoding: %s", name)
+		}
+		e, err := ParseEncoding(encName)
+		if err != nil {
+			return nil, err
+		}
+		perAlgo[name] = e
+	}
+
+	result := make([]Hasher, len(hashers))
+	for i, h := range hashers {
+		result[i] = h
+		if e, ok := perAlgo[h.Name()]; ok {
+			wrapped, err := WithEncoding(h, e)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = wrapped
+			delete(perAlgo, h.Name())
+		} else if _, fuzzy := h.(FuzzyHasher); !fuzzy && global != nil {
+			result[i], _ = WithEncoding(h, *global)
+		}
+	}
+
+	for name := range perAlgo {
+		return nil, fmt.Errorf("encoding given for unselected algorithm: %s", name)
+	}
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package hasher
+
+import (
+	"hash"
+
+	"github.com/Virace/fast-hasher/pkg/ssdeep"
+	"github.com/Virace/fast-hasher/pkg/tlsh"
+)
+
+// Fuzzy (similarity) hashers. Their digests are already text, so Encoding is not used.
+
+type ssdeepHasher struct{}
+
+func (ssdeepHasher) Name() string                     { return "ssdeep" }
+func (ssdeepHasher) New() hash.Hash                   { return ssdeep.New() }
+func (ssdeepHasher) OutputSize() int                  { return ssdeep.MaxResultLength }
+func (ssdeepHasher) Encoding() Encoding               { return Hex }
+func (ssdeepHasher) Compare(a, b string) (int, error) { return ssdeep.Compare(a, b) }
+func (ssdeepHasher) IsDistance() bool                 { return false } // 0-100 match score
+
+type tlshHasher struct{}
+
+func (tlshHasher) Name() string                     { return "tlsh" }
+func (tlshHasher) New() hash.Hash                   { return tlsh.New() }
+func (tlshHasher) OutputSize() int                  { return tlsh.Size }
+func (tlshHasher) Encoding() Encoding               { return Hex }
+func (tlshHasher) Compare(a, b string) (int, error) { return tlsh.Diff(a, b) }
+func (tlshHasher) IsDistance() bool                 { return true } // 0 = identical
+
+func init() {
+	Register(ssdeepHasher{})
+	Register(tlshHasher{})
+}
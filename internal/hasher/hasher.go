@@ -2,12 +2,9 @@
 package hasher
 
 import (
-	"encoding/base64"
-	"encoding/hex"
 	"fmt"
 	"hash"
 	"io"
-	"os"
 )
 
 // Hasher represents a hash algorithm that can be used to compute checksums.
@@ -18,8 +15,20 @@ type Hasher interface {
 	New() hash.Hash
 	// OutputSize returns the size of the hash output in bytes
 	OutputSize() int
-	// IsBase64 returns true if the hash should be encoded as base64 (e.g., quickxor)
-	IsBase64() bool
+	// Encoding returns how the digest is rendered as text (e.g., base64 for quickxor)
+	Encoding() Encoding
+}
+
+// FuzzyHasher is implemented by similarity hashers (e.g. ssdeep, tlsh).
+// Their Sum returns a printable digest string rather than raw bytes, and
+// two digests can be compared to find near matches.
+type FuzzyHasher interface {
+	Hasher
+	// Compare returns the similarity of two digests produced by this hasher.
+	Compare(a, b string) (int, error)
+	// IsDistance reports whether Compare returns a distance (lower is more
+	// similar) rather than a match score (higher is more similar).
+	IsDistance() bool
 }
 
 // HashResult holds the hash result for a single algorithm.
@@ -32,47 +41,132 @@ type HashResult struct {
 // HashReader computes hashes from an io.Reader using multiple hashers simultaneously.
 // This reads the data only once, computing all hashes in parallel.
 func HashReader(r io.Reader, hashers []Hasher) (map[string]string, error) {
+	hashes, _, err := HashReaderChunks(r, hashers, 0)
+	return hashes, err
+}
+
+// HashReaderChunks computes hashes like HashReader and, if chunkSize is
+// positive, also the digests of consecutive chunkSize-byte blocks of the data
+// (the last block may be shorter) for each algorithm. Fuzzy hashers produce
+// no chunk list. Chunk digests use the same encoding as the whole-file digest.
+func HashReaderChunks(r io.Reader, hashers []Hasher, chunkSize int64) (map[string]string, map[string][]string, error) {
 	if len(hashers) == 0 {
-		return nil, fmt.Errorf("no hashers provided")
+		return nil, nil, fmt.Errorf("no hashers provided")
 	}
 
 	// Create hash instances
 	hashes := make([]hash.Hash, len(hashers))
-	writers := make([]io.Writer, len(hashers))
+	writers := make([]io.Writer, len(hashers), len(hashers)+1)
 	for i, h := range hashers {
 		hashes[i] = h.New()
 		writers[i] = hashes[i]
 	}
 
+	var chunks *chunkWriter
+	if chunkSize > 0 {
+		chunks = newChunkWriter(hashers, chunkSize)
+		writers = append(writers, chunks)
+	}
+
 	// Create a multi-writer to write to all hashes simultaneously
 	mw := io.MultiWriter(writers...)
 
-	// Copy data to all hashes
-	if _, err := io.Copy(mw, r); err != nil {
-		return nil, fmt.Errorf("failed to read data: %w", err)
+	// Copy data to all hashes; files opened by this package bring their own
+	// buffers or memory mapping
+	buf := getBuffer(DefaultBufferSize)
+	defer putBuffer(buf)
+	if _, err := io.CopyBuffer(mw, r, *buf); err != nil {
+		return nil, nil, fmt.Errorf("failed to read data: %w", err)
 	}
 
 	// Collect results
 	results := make(map[string]string, len(hashers))
 	for i, h := range hashers {
 		sum := hashes[i].Sum(nil)
-		if h.IsBase64() {
-			results[h.Name()] = base64.StdEncoding.EncodeToString(sum)
+		if _, ok := h.(FuzzyHasher); ok {
+			results[h.Name()] = string(sum)
 		} else {
-			results[h.Name()] = hex.EncodeToString(sum)
+			results[h.Name()] = h.Encoding().Encode(sum)
 		}
 	}
 
-	return results, nil
+	if chunks == nil {
+		return results, nil, nil
+	}
+	return results, chunks.finish(), nil
 }
 
 // HashFile computes hashes for a file using multiple hashers.
 func HashFile(path string, hashers []Hasher) (map[string]string, error) {
-	f, err := os.Open(path)
+	hashes, _, err := HashFileChunks(path, hashers, 0, IOOptions{})
+	return hashes, err
+}
+
+// HashFileChunks computes hashes and per-chunk digests for a file, reading it
+// as configured by ioOpts. See HashReaderChunks.
+func HashFileChunks(path string, hashers []Hasher, chunkSize int64, ioOpts IOOptions) (map[string]string, map[string][]string, error) {
+	f, err := openFile(path, ioOpts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, nil, err
 	}
 	defer f.Close()
 
-	return HashReader(f, hashers)
+	return HashReaderChunks(f, hashers, chunkSize)
+}
+
+// chunkWriter hashes consecutive fixed-size blocks of the data written to it.
+type chunkWriter struct {
+	hashers []Hasher // non-fuzzy hashers
+	current []hash.Hash
+	size    int64 // chunk size
+	n       int64 // bytes written to the current chunk
+	digests map[string][]string
+}
+
+func newChunkWriter(hashers []Hasher, size int64) *chunkWriter {
+	w := &chunkWriter{size: size, digests: make(map[string][]string)}
+	for _, h := range hashers {
+		if _, ok := h.(FuzzyHasher); ok {
+			continue
+		}
+		w.hashers = append(w.hashers, h)
+		w.current = append(w.current, h.New())
+		w.digests[h.Name()] = []string{}
+	}
+	return w
+}
+
+// Write feeds p to the chunk hashers, starting a new chunk every size bytes.
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		if w.n == w.size {
+			w.flush()
+		}
+		n := min(int64(len(p)), w.size-w.n)
+		for _, h := range w.current {
+			h.Write(p[:n])
+		}
+		w.n += n
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// flush records the digests of the current chunk and starts a new one.
+func (w *chunkWriter) flush() {
+	for i, h := range w.hashers {
+		w.digests[h.Name()] = append(w.digests[h.Name()], h.Encoding().Encode(w.current[i].Sum(nil)))
+		w.current[i].Reset()
+	}
+	w.n = 0
+}
+
+// finish returns the chunk digests, including a trailing partial chunk.
+// Empty input has no chunks.
+func (w *chunkWriter) finish() map[string][]string {
+	if w.n > 0 {
+		w.flush()
+	}
+	return w.digests
 }
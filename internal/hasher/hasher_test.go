@@ -2,17 +2,27 @@ package hasher
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
+	"hash/crc32"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/Virace/fast-hasher/internal/ratelimit"
+	"github.com/Virace/fast-hasher/pkg/fastcdc"
 )
 
 func TestRegisteredHashers(t *testing.T) {
-	expected := []string{"blake3", "crc32", "md5", "quickxor", "sha1", "sha256", "sha512", "xxh128", "xxh3"}
+	expected := []string{"blake3", "crc32", "ed2k", "md5", "quickxor", "sha1", "sha256", "sha384", "sha512", "ssdeep", "tlsh", "xxh128", "xxh3"}
 	registered := List()
 
 	if len(registered) != len(expected) {
@@ -183,6 +193,606 @@ func TestAllHashers(t *testing.T) {
 	}
 }
 
+func TestFuzzyHashers(t *testing.T) {
+	data := []byte("Also called fuzzy hashes, Ctph can match inputs that have homologies.")
+
+	hashers, err := Parse("ssdeep,tlsh")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	results, err := HashReader(bytes.NewReader(data), hashers)
+	if err != nil {
+		t.Fatalf("HashReader failed: %v", err)
+	}
+
+	// Fuzzy digests are returned as-is, not hex encoded
+	if got, want := results["ssdeep"], "3:AXGBicFlgVNhBGcL6wCrFQEv:AXGHsNhxLsr2C"; got != want {
+		t.Errorf("ssdeep: got %s, want %s", got, want)
+	}
+	if got := results["tlsh"]; got != "TNULL" && !strings.HasPrefix(got, "T1") {
+		t.Errorf("tlsh: unexpected digest %s", got)
+	}
+
+	for _, h := range hashers {
+		fh, ok := h.(FuzzyHasher)
+		if !ok {
+			t.Fatalf("%s does not implement FuzzyHasher", h.Name())
+		}
+		if h.Name() == "ssdeep" {
+			score, err := fh.Compare(results["ssdeep"], results["ssdeep"])
+			if err != nil || score != 100 {
+				t.Errorf("ssdeep self-compare = %d, %v; want 100", score, err)
+			}
+		}
+	}
+
+	md5, _ := Get("md5")
+	if _, ok := md5.(FuzzyHasher); ok {
+		t.Error("md5 should not implement FuzzyHasher")
+	}
+}
+
+func TestKeyed(t *testing.T) {
+	data := []byte("hello world")
+	key := []byte("secret key")
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	hashers, _ := Parse("sha256,quickxor")
+	keyed, err := Keyed(hashers, key)
+	if err != nil {
+		t.Fatalf("Keyed failed: %v", err)
+	}
+	if keyed[0].Name() != "hmac-sha256" || keyed[1].Name() != "hmac-quickxor" {
+		t.Errorf("unexpected names: %s, %s", keyed[0].Name(), keyed[1].Name())
+	}
+	if keyed[1].Encoding() != Base64 {
+		t.Error("hmac-quickxor should keep base64 encoding")
+	}
+
+	results, err := HashReader(bytes.NewReader(data), keyed)
+	if err != nil {
+		t.Fatalf("HashReader failed: %v", err)
+	}
+	if got := results["hmac-sha256"]; got != expected {
+		t.Errorf("hmac-sha256: got %s, want %s", got, expected)
+	}
+
+	if _, err := Keyed(hashers, nil); err == nil {
+		t.Error("Keyed with empty key expected error, got nil")
+	}
+	fuzzy, _ := Parse("ssdeep")
+	if _, err := Keyed(fuzzy, key); err == nil {
+		t.Error("Keyed with fuzzy hasher expected error, got nil")
+	}
+}
+
+func TestEncoding_Encode(t *testing.T) {
+	sum := []byte{0xfb, 0xff, 0x00, 0x10}
+
+	tests := []struct {
+		encoding Encoding
+		want     string
+	}{
+		{Hex, "fbff0010"},
+		{HexUpper, "FBFF0010"},
+		{Base64, "+/8AEA=="},
+		{Base64URL, "-_8AEA"},
+		{Base32, "7P7QAEA="},
+		{Multibase, "b7p7qaea"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding.String(), func(t *testing.T) {
+			got := tt.encoding.Encode(sum)
+			if got != tt.want {
+				t.Errorf("Encode() = %q, want %q", got, tt.want)
+			}
+
+			// Every encoding must be accepted back by Decode
+			decoded, err := Decode(got, len(sum))
+			if err != nil {
+				t.Fatalf("Decode(%q) error: %v", got, err)
+			}
+			if !bytes.Equal(decoded, sum) {
+				t.Errorf("Decode(%q) = %x, want %x", got, decoded, sum)
+			}
+		})
+	}
+
+	if _, err := Decode("not a digest", len(sum)); err == nil {
+		t.Error("Decode(invalid) expected error, got nil")
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for input, want := range map[string]Encoding{
+		"hex":       Hex,
+		"HEX":       HexUpper,
+		"upper-hex": HexUpper,
+		"base64":    Base64,
+		"Base64URL": Base64URL,
+		"base32":    Base32,
+		"multibase": Multibase,
+	} {
+		got, err := ParseEncoding(input)
+		if err != nil || got != want {
+			t.Errorf("ParseEncoding(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseEncoding("base58"); err == nil {
+		t.Error("ParseEncoding(base58) expected error, got nil")
+	}
+}
+
+func TestApplyEncodings(t *testing.T) {
+	data := []byte("hello world")
+	expectedSHA256 := sha256.Sum256(data)
+	expectedMD5 := md5.Sum(data)
+
+	hashers, _ := Parse("sha256,md5,quickxor,ssdeep")
+	encoded, err := ApplyEncodings(hashers, "base64, md5=HEX, quickxor=hex")
+	if err != nil {
+		t.Fatalf("ApplyEncodings failed: %v", err)
+	}
+
+	results, err := HashReader(bytes.NewReader(data), encoded)
+	if err != nil {
+		t.Fatalf("HashReader failed: %v", err)
+	}
+
+	if got, want := results["sha256"], base64.StdEncoding.EncodeToString(expectedSHA256[:]); got != want {
+		t.Errorf("sha256: got %s, want %s", got, want)
+	}
+	if got, want := results["md5"], strings.ToUpper(hex.EncodeToString(expectedMD5[:])); got != want {
+		t.Errorf("md5: got %s, want %s", got, want)
+	}
+	if got := results["quickxor"]; len(got) != 40 {
+		t.Errorf("quickxor: expected 40 hex chars, got %s", got)
+	}
+	if got := results["ssdeep"]; !strings.HasPrefix(got, "3:") {
+		t.Errorf("ssdeep should be unaffected by the default encoding, got %s", got)
+	}
+
+	errorSpecs := []string{"base58", "nosuch=hex", "sha512=hex", "ssdeep=base64"}
+	for _, spec := range errorSpecs {
+		if _, err := ApplyEncodings(hashers, spec); err == nil {
+			t.Errorf("ApplyEncodings(%q) expected error, got nil", spec)
+		}
+	}
+}
+
+func TestHashReaderChunks(t *testing.T) {
+	data := []byte("0123456789abcdefghij") // 20 bytes: chunks of 8, 8 and 4
+	hashers, _ := Parse("md5,ssdeep")
+
+	hashes, chunks, err := HashReaderChunks(bytes.NewReader(data), hashers, 8)
+	if err != nil {
+		t.Fatalf("HashReaderChunks failed: %v", err)
+	}
+
+	whole := md5.Sum(data)
+	if got := hashes["md5"]; got != hex.EncodeToString(whole[:]) {
+		t.Errorf("md5 = %s, want %x", got, whole)
+	}
+
+	want := []string{}
+	for _, chunk := range [][]byte{data[:8], data[8:16], data[16:]} {
+		sum := md5.Sum(chunk)
+		want = append(want, hex.EncodeToString(sum[:]))
+	}
+	if got := chunks["md5"]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("md5 chunks = %v, want %v", got, want)
+	}
+	if _, ok := chunks["ssdeep"]; ok {
+		t.Error("fuzzy hashers must not produce chunk lists")
+	}
+
+	// Exact multiple of the chunk size: no trailing empty chunk
+	_, chunks, _ = HashReaderChunks(bytes.NewReader(data[:16]), hashers, 8)
+	if n := len(chunks["md5"]); n != 2 {
+		t.Errorf("got %d chunks for 16 bytes, want 2", n)
+	}
+
+	// Empty input has no chunks
+	_, chunks, _ = HashReaderChunks(bytes.NewReader(nil), hashers, 8)
+	if n := len(chunks["md5"]); n != 0 {
+		t.Errorf("got %d chunks for empty input, want 0", n)
+	}
+}
+
+func TestHashReaderCDC(t *testing.T) {
+	data := make([]byte, 100*1024)
+	for i := range data {
+		data[i] = byte(i * 7 % 253)
+	}
+	hashers, _ := Parse("sha256,ssdeep")
+	opts := fastcdc.DefaultOptions(4096)
+
+	hashes, chunks, err := HashReaderCDC(bytes.NewReader(data), hashers, opts)
+	if err != nil {
+		t.Fatalf("HashReaderCDC failed: %v", err)
+	}
+
+	whole := sha256.Sum256(data)
+	if got := hashes["sha256"]; got != hex.EncodeToString(whole[:]) {
+		t.Errorf("sha256 = %s, want %x", got, whole)
+	}
+	if hashes["ssdeep"] == "" {
+		t.Error("missing whole-file ssdeep hash")
+	}
+
+	var offset int64
+	for i, c := range chunks {
+		if c.Offset != offset {
+			t.Fatalf("chunk %d offset = %d, want %d", i, c.Offset, offset)
+		}
+		sum := sha256.Sum256(data[c.Offset : c.Offset+int64(c.Length)])
+		if got := c.Hashes["sha256"]; got != hex.EncodeToString(sum[:]) {
+			t.Errorf("chunk %d sha256 = %s, want %x", i, got, sum)
+		}
+		if _, ok := c.Hashes["ssdeep"]; ok {
+			t.Errorf("chunk %d has a fuzzy hash", i)
+		}
+		offset += int64(c.Length)
+	}
+	if offset != int64(len(data)) {
+		t.Errorf("chunks cover %d bytes, want %d", offset, len(data))
+	}
+
+	fuzzyOnly, _ := Parse("ssdeep")
+	if _, _, err := HashReaderCDC(bytes.NewReader(data), fuzzyOnly, opts); err == nil {
+		t.Error("fuzzy-only hashers: expected error, got nil")
+	}
+}
+
+func TestSample_Reader(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	withSize := func(parts ...[]byte) []byte {
+		var out []byte
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return binary.BigEndian.AppendUint64(out, uint64(len(data)))
+	}
+
+	tests := []struct {
+		name   string
+		sample Sample
+		want   []byte
+	}{
+		{"range", Sample{Offset: 100, Length: 50}, data[100:150]},
+		{"range to end", Sample{Offset: 900}, data[900:]},
+		{"range past end", Sample{Offset: 2000, Length: 10}, nil},
+		{"head/tail", Sample{HeadTail: 10}, withSize(data[:10], data[990:])},
+		{"head/tail small file", Sample{HeadTail: 600}, withSize(data)},
+		{"samples", Sample{Count: 3, SampleSize: 10}, withSize(data[:10], data[495:505], data[990:])},
+		{"one sample", Sample{Count: 1, SampleSize: 10}, withSize(data[:10])},
+		{"samples small file", Sample{Count: 4, SampleSize: 300}, withSize(data)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(tt.sample.Reader(bytes.NewReader(data), int64(len(data))))
+			if err != nil {
+				t.Fatalf("ReadAll failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %d bytes, want %d bytes", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSample_Validate(t *testing.T) {
+	invalid := []Sample{
+		{Offset: -1},
+		{Count: 2},
+		{HeadTail: 10, Count: 2, SampleSize: 10},
+		{HeadTail: 10, Offset: 5},
+		{Count: 2, SampleSize: 10, Length: 5},
+	}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected error, got nil", s)
+		}
+	}
+	if err := (Sample{HeadTail: 10}).Validate(); err != nil {
+		t.Errorf("Validate head/tail: %v", err)
+	}
+}
+
+func TestSampled(t *testing.T) {
+	hashers, _ := Parse("sha256")
+	sample := Sample{HeadTail: 4}
+	sampled, err := Sampled(hashers, sample)
+	if err != nil {
+		t.Fatalf("Sampled failed: %v", err)
+	}
+	if got := sampled[0].Name(); got != "sha256@headtail=4" {
+		t.Errorf("Name() = %q, want %q", got, "sha256@headtail=4")
+	}
+
+	path := filepath.Join(t.TempDir(), "sample.bin")
+	data := []byte("0123456789abcdef")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	hashes, err := HashFileSample(path, sampled, sample, IOOptions{})
+	if err != nil {
+		t.Fatalf("HashFileSample failed: %v", err)
+	}
+	want := sha256.Sum256(binary.BigEndian.AppendUint64([]byte("0123cdef"), uint64(len(data))))
+	if got := hashes["sha256@headtail=4"]; got != hex.EncodeToString(want[:]) {
+		t.Errorf("sha256 = %s, want %x", got, want)
+	}
+
+	fuzzy, _ := Parse("ssdeep")
+	if _, err := Sampled(fuzzy, sample); err == nil {
+		t.Error("fuzzy hasher: expected error, got nil")
+	}
+}
+
+func TestHashFileParallel(t *testing.T) {
+	// Use small segments so a few segments fit in a small test file
+	defer func(size int64) { parallelSegmentSize = size }(parallelSegmentSize)
+	parallelSegmentSize = 64 * 1024
+
+	tmpDir := t.TempDir()
+	for _, size := range []int{100, 64 * 1024, 64*1024 + 1, 9*64*1024 - 3, 16 * 64 * 1024} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		path := filepath.Join(tmpDir, "file")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, algo := range []string{"blake3", "crc32", "blake3,crc32", "crc32,sha256"} {
+			hashers, _ := Parse(algo)
+			want, err := HashFile(path, hashers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			workers := NewWorkers(blake3MinWorkers)
+			workers.Acquire()
+			got, err := HashFileParallel(path, hashers, workers, IOOptions{})
+			if err != nil {
+				t.Fatalf("HashFileParallel(%s) failed: %v", algo, err)
+			}
+			for name, hash := range want {
+				if got[name] != hash {
+					t.Errorf("size %d, %s: got %s, want %s", size, name, got[name], hash)
+				}
+			}
+			if n := len(workers); n != 1 {
+				t.Errorf("size %d, %s: %d workers held after hashing, want 1", size, algo, n)
+			}
+		}
+	}
+}
+
+func TestHashFileIO(t *testing.T) {
+	// Map even small files so the mmap path is exercised
+	defer func(size int64) { mmapMinSize = size }(mmapMinSize)
+	mmapMinSize = 1
+	defer func(size int64) { parallelSegmentSize = size }(parallelSegmentSize)
+	parallelSegmentSize = 64 * 1024
+
+	data := make([]byte, 5*64*1024+17)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hashers, _ := Parse("crc32,sha256")
+	want, err := HashReader(bytes.NewReader(data), hashers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modes := map[string]IOOptions{
+		"default":   {},
+		"small":     {BufferSize: 1000},
+		"mmap":      {Mmap: true, BufferSize: 4096},
+		"dropcache": {DropCache: true},
+		"direct":    {Direct: true},
+		// Rounded up to the direct I/O alignment
+		"direct-small": {Direct: true, BufferSize: 1000},
+	}
+	for name, opts := range modes {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := HashFileChunks(path, hashers, 0, opts)
+			if err != nil {
+				t.Fatalf("HashFileChunks failed: %v", err)
+			}
+			crc, _ := Parse("crc32")
+			workers := NewWorkers(4)
+			workers.Acquire()
+			segmented, err := HashFileParallel(path, crc, workers, opts)
+			if err != nil {
+				t.Fatalf("HashFileParallel failed: %v", err)
+			}
+			for algo, hash := range want {
+				if got[algo] != hash {
+					t.Errorf("%s: got %s, want %s", algo, got[algo], hash)
+				}
+			}
+			if segmented["crc32"] != want["crc32"] {
+				t.Errorf("segmented crc32: got %s, want %s", segmented["crc32"], want["crc32"])
+			}
+		})
+	}
+
+	if err := (IOOptions{BufferSize: -1}).Validate(); err == nil {
+		t.Error("negative buffer size: expected error, got nil")
+	}
+	if err := (IOOptions{Direct: true, Mmap: true}).Validate(); err == nil {
+		t.Error("direct with mmap: expected error, got nil")
+	}
+	for name, opts := range map[string]IOOptions{"mmap": {Mmap: true}, "dropcache": {DropCache: true}, "direct": {Direct: true}} {
+		if err := opts.Validate(); (err == nil) != ioModesSupported {
+			t.Errorf("%s: Validate() = %v, supported = %v", name, err, ioModesSupported)
+		}
+	}
+}
+
+func TestHashFileIO_Limiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, make([]byte, 9000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hashers, _ := Parse("md5")
+
+	// 8000 bytes/s lets the first 8000 bytes through, then paces the rest
+	opts := IOOptions{Limiter: ratelimit.New(8000, 0), BufferSize: 1000}
+	start := time.Now()
+	if _, _, err := HashFileChunks(path, hashers, 0, opts); err != nil {
+		t.Fatalf("HashFileChunks failed: %v", err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("9000 bytes at 8000/s took %v, want at least 125ms", d)
+	}
+}
+
+func TestHashFileIO_Sparse(t *testing.T) {
+	defer func(size int64) { parallelSegmentSize = size }(parallelSegmentSize)
+	parallelSegmentSize = 64 * 1024
+
+	// Data around holes at the start, in the middle and at the end
+	data := make([]byte, 3*1024*1024+7)
+	path := filepath.Join(t.TempDir(), "sparse")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []int{1024*1024 + 123, 2 * 1024 * 1024} {
+		for i := off; i < off+100000; i++ {
+			data[i] = byte(i % 251)
+		}
+		f.WriteAt(data[off:off+100000], int64(off))
+	}
+	f.Truncate(int64(len(data)))
+	f.Close()
+
+	if f, err := openFile(path, IOOptions{}); err == nil {
+		if !f.sparse {
+			t.Log("filesystem does not support holes; reading normally")
+		}
+		f.Close()
+	}
+
+	hashers, _ := Parse("crc32,sha256")
+	want, _ := HashReader(bytes.NewReader(data), hashers)
+	for name, opts := range map[string]IOOptions{"default": {}, "small": {BufferSize: 1000}, "direct": {Direct: true}} {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := HashFileChunks(path, hashers, 0, opts)
+			if err != nil {
+				t.Fatalf("HashFileChunks failed: %v", err)
+			}
+			crc, _ := Parse("crc32")
+			workers := NewWorkers(4)
+			workers.Acquire()
+			segmented, err := HashFileParallel(path, crc, workers, opts)
+			if err != nil {
+				t.Fatalf("HashFileParallel failed: %v", err)
+			}
+			if got["sha256"] != want["sha256"] || got["crc32"] != want["crc32"] || segmented["crc32"] != want["crc32"] {
+				t.Errorf("got %v, segmented %v, want %v", got, segmented, want)
+			}
+		})
+	}
+}
+
+func TestHashFileIO_DirectUnaligned(t *testing.T) {
+	data := make([]byte, 3*directAlignment+123)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sampling reads at arbitrary offsets
+	hashers, _ := Parse("md5")
+	sample := Sample{Offset: 1000, Length: 2 * directAlignment}
+	want, _ := HashReader(bytes.NewReader(data[1000:1000+2*directAlignment]), hashers)
+	got, err := HashFileSample(path, hashers, sample, IOOptions{Direct: true})
+	if err != nil {
+		t.Fatalf("HashFileSample failed: %v", err)
+	}
+	if got["md5"] != want["md5"] {
+		t.Errorf("sample md5: got %s, want %s", got["md5"], want["md5"])
+	}
+
+	// Exercise the aligned block reads even where O_DIRECT is unavailable
+	f, err := openFile(path, IOOptions{Direct: true, BufferSize: directAlignment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.direct.Store(true)
+	for _, read := range []struct{ offset, length int }{
+		{0, 10}, {5, directAlignment}, {directAlignment - 1, 2*directAlignment + 7}, {len(data) - 50, 100},
+	} {
+		p := make([]byte, read.length)
+		n, err := f.ReadAt(p, int64(read.offset))
+		end := min(read.offset+read.length, len(data))
+		if n != end-read.offset || !bytes.Equal(p[:n], data[read.offset:end]) {
+			t.Errorf("ReadAt(%d, %d) = %d bytes, %v", read.offset, read.length, n, err)
+		}
+		if end < read.offset+read.length && err != io.EOF {
+			t.Errorf("ReadAt(%d, %d) past the end: err = %v, want io.EOF", read.offset, read.length, err)
+		}
+	}
+}
+
+func TestHashFileIO_TruncatedMapping(t *testing.T) {
+	defer func(size int64) { mmapMinSize = size }(mmapMinSize)
+	mmapMinSize = 1
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, make([]byte, 1<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openFile(path, IOOptions{Mmap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.data == nil {
+		t.Skip("mmap not supported")
+	}
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	hashers, _ := Parse("md5")
+	if _, err := HashReader(f, hashers); err == nil {
+		t.Error("expected error reading a truncated mapping, got nil")
+	}
+}
+
+func TestCRC32Combine(t *testing.T) {
+	a, b := []byte("hello "), []byte("world, this is a longer second part")
+	want := crc32.ChecksumIEEE(append(append([]byte{}, a...), b...))
+	got := crc32Combine(crc32.ChecksumIEEE(a), crc32.ChecksumIEEE(b), int64(len(b)))
+	if got != want {
+		t.Errorf("crc32Combine = %08x, want %08x", got, want)
+	}
+}
+
 func BenchmarkHashers(b *testing.B) {
 	data := make([]byte, 1024*1024) // 1MB
 	for i := range data {
@@ -200,3 +810,37 @@ func BenchmarkHashers(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkHashFileIO compares the file reading modes on a file that is in
+// the page cache after the first iteration.
+func BenchmarkHashFileIO(b *testing.B) {
+	data := make([]byte, 64*1024*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	path := filepath.Join(b.TempDir(), "file")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	hashers, _ := Parse("xxh3")
+
+	modes := []struct {
+		name string
+		opts IOOptions
+	}{
+		{"buffer-32k", IOOptions{BufferSize: 32 * 1024}},
+		{"buffer-256k", IOOptions{BufferSize: 256 * 1024}},
+		{"buffer-1m", IOOptions{BufferSize: 1024 * 1024}},
+		{"mmap", IOOptions{Mmap: true}},
+	}
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, _, err := HashFileChunks(path, hashers, 0, mode.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"hash"
+)
+
+// HMACPrefix is prepended to the algorithm name of keyed hashers (e.g. "hmac-sha256").
+const HMACPrefix = "hmac-"
+
+// hmacHasher wraps a hasher with HMAC (RFC 2104) using a secret key.
+type hmacHasher struct {
+	inner Hasher
+	key   []byte
+}
+
+func (h hmacHasher) Name() string       { return HMACPrefix + h.inner.Name() }
+func (h hmacHasher) New() hash.Hash     { return hmac.New(h.inner.New, h.key) }
+func (h hmacHasher) OutputSize() int    { return h.inner.OutputSize() }
+func (h hmacHasher) Encoding() Encoding { return h.inner.Encoding() }
+
+// NewHMAC returns a keyed hasher computing HMAC with the given hasher.
+// Fuzzy hashers cannot be keyed because their digests are not fixed-size.
+func NewHMAC(h Hasher, key []byte) (Hasher, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("empty HMAC key")
+	}
+	if _, ok := h.(FuzzyHasher); ok {
+		return nil, fmt.Errorf("algorithm %s cannot be used with HMAC", h.Name())
+	}
+	return hmacHasher{inner: h, key: key}, nil
+}
+
+// Keyed wraps every hasher with HMAC using the given key. See NewHMAC.
+func Keyed(hashers []Hasher, key []byte) ([]Hasher, error) {
+	keyed := make([]Hasher, len(hashers))
+	for i, h := range hashers {
+		k, err := NewHMAC(h, key)
+		if err != nil {
+			return nil, err
+		}
+		keyed[i] = k
+	}
+	return keyed, nil
+}
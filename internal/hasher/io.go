@@ -0,0 +1,353 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/Virace/fast-hasher/internal/ratelimit"
+)
+
+// DefaultBufferSize is the read buffer size used when IOOptions.BufferSize
+// is zero. It is larger than io.Copy's 32 KiB, which costs too many syscalls
+// on NVMe and network filesystems, but still fits in a typical L2 cache so
+// that every hasher sees the data while it is hot.
+const DefaultBufferSize = 256 * 1024
+
+// MaxBufferSize is the largest allowed IOOptions.BufferSize.
+const MaxBufferSize = 64 * 1024 * 1024
+
+// directAlignment is the buffer address, offset and length alignment
+// required for direct I/O. It covers the logical block size of all common
+// devices.
+const directAlignment = 4096
+
+// mmapMinSize is the smallest file that is memory-mapped when
+// IOOptions.Mmap is set; smaller files are cheaper to read.
+var mmapMinSize int64 = 4 * 1024 * 1024
+
+// IOOptions controls how files are read for hashing.
+type IOOptions struct {
+	BufferSize int  // Read buffer size in bytes (default: DefaultBufferSize)
+	Mmap       bool // Map large files into memory instead of reading them (Linux only)
+	DropCache  bool // Drop hashed files from the page cache when done (Linux only)
+	// Direct reads files with O_DIRECT, bypassing the page cache, so that
+	// data comes from the disk and does not evict other data (Linux only).
+	// Where the filesystem rejects it, files are read normally but evicted
+	// from the page cache before and after hashing.
+	Direct bool
+	// Limiter throttles the bytes read and files opened, if set. It may be
+	// shared by several scanners.
+	Limiter *ratelimit.Limiter
+}
+
+// Validate checks that the options are usable.
+func (o IOOptions) Validate() error {
+	if o.BufferSize < 0 || o.BufferSize > MaxBufferSize {
+		return fmt.Errorf("buffer size must be between 0 and %d bytes", MaxBufferSize)
+	}
+	if o.Direct && o.Mmap {
+		return fmt.Errorf("direct I/O cannot be combined with mmap")
+	}
+	if !ioModesSupported && (o.Mmap || o.DropCache || o.Direct) {
+		return fmt.Errorf("mmap, drop-cache and direct I/O are not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	return nil
+}
+
+// bufferSize returns the read buffer size, a multiple of directAlignment in
+// direct mode.
+func (o IOOptions) bufferSize() int {
+	size := DefaultBufferSize
+	if o.BufferSize > 0 {
+		size = o.BufferSize
+	}
+	if o.Direct {
+		size = (size + directAlignment - 1) &^ (directAlignment - 1)
+	}
+	return size
+}
+
+// bufferPools holds a *sync.Pool of read buffers per buffer size, shared by
+// all workers so that hashing many files does not allocate a buffer each.
+// Buffers are aligned for direct I/O.
+var bufferPools sync.Map
+
+func getBuffer(size int) *[]byte {
+	pool, _ := bufferPools.LoadOrStore(size, &sync.Pool{
+		New: func() any {
+			buf := make([]byte, size+directAlignment)
+			skip := -int(uintptr(unsafe.Pointer(&buf[0]))) & (directAlignment - 1)
+			buf = buf[skip : skip+size]
+			return &buf
+		},
+	})
+	return pool.(*sync.Pool).Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	if pool, ok := bufferPools.Load(len(*buf)); ok {
+		pool.(*sync.Pool).Put(buf)
+	}
+}
+
+// file is a file opened for hashing. It reads through pooled buffers of the
+// configured size, or from a memory mapping, and implements io.WriterTo so
+// that io.Copy uses them. In direct mode, Read and ReadAt accept any offset
+// and buffer by reading whole aligned blocks. Holes in sparse files are
+// returned as zeros without reading them.
+type file struct {
+	*os.File
+	opts   IOOptions
+	size   int64       // size when opened
+	data   []byte      // memory mapping of the first size bytes, if any
+	direct atomic.Bool // O_DIRECT is set
+	sparse bool        // the file has holes, found with SEEK_DATA and SEEK_HOLE
+	offset int64       // read offset in direct and sparse mode
+}
+
+// openFile opens a file for hashing, hinting the kernel that it will be read
+// sequentially and mapping it into memory if requested.
+func openFile(path string, opts IOOptions) (*file, error) {
+	opts.Limiter.WaitFile()
+
+	var f *os.File
+	var err error
+	direct := false
+	if opts.Direct {
+		f, err = openDirect(path)
+		direct = err == nil
+	}
+	if !direct {
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	file := &file{File: f, opts: opts, size: info.Size()}
+	if info.Mode()&os.ModeDevice != 0 {
+		// Block devices report no size; it is where their end is
+		if file.size, err = f.Seek(0, io.SeekEnd); err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to get device size: %w", err)
+		}
+	}
+	file.direct.Store(direct)
+	if opts.Direct && !direct {
+		// Without O_DIRECT, evict cached pages so that reads hit the disk
+		fadviseDontNeed(f)
+	}
+	fadviseSequential(f)
+	if opts.Mmap && file.size >= mmapMinSize {
+		// Fall back to reading if the file cannot be mapped
+		file.data, _ = mmap(f, file.size)
+	}
+	// Mapped holes read as zeros without I/O anyway
+	file.sparse = file.data == nil && isSparse(info)
+	return file, nil
+}
+
+// Close unmaps and closes the file, dropping it from the page cache if
+// requested.
+func (f *file) Close() error {
+	if f.data != nil {
+		munmap(f.data)
+		f.data = nil
+	}
+	if f.opts.DropCache || (f.opts.Direct && !f.direct.Load()) {
+		fadviseDontNeed(f.File)
+	}
+	return f.File.Close()
+}
+
+// Read reads from the current offset, in aligned blocks in direct mode.
+// With direct I/O requested, the offset is tracked here rather than by the
+// file, so that it survives clearing O_DIRECT in the middle of the file.
+// Sparse files track it too, since finding holes moves the file's offset.
+func (f *file) Read(p []byte) (n int, err error) {
+	if f.opts.Direct || f.sparse {
+		n, err = f.readAt(p, f.offset)
+		f.offset += int64(n)
+		if n > 0 && err == io.EOF {
+			err = nil
+		}
+	} else {
+		n, err = f.File.Read(p)
+	}
+	f.opts.Limiter.WaitBytes(n)
+	return n, err
+}
+
+// ReadAt reads at the given offset, in aligned blocks in direct mode.
+func (f *file) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := f.readAt(p, offset)
+	f.opts.Limiter.WaitBytes(n)
+	return n, err
+}
+
+func (f *file) readAt(p []byte, offset int64) (int, error) {
+	if f.sparse {
+		return f.readAtSparse(p, offset)
+	}
+	return f.readAtData(p, offset)
+}
+
+// readAtSparse reads the data of a sparse file and fills holes with zeros,
+// giving the same bytes as reading the holes. If holes cannot be found, the
+// rest is read normally.
+func (f *file) readAtSparse(p []byte, offset int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := offset + int64(n)
+		data, err := seekData(f.File, pos)
+		if errors.Is(err, syscall.ENXIO) {
+			data = f.size // No data after pos: a hole up to the end
+		} else if err != nil {
+			m, err := f.readAtData(p[n:], pos)
+			return n + m, err
+		}
+
+		if data > pos {
+			if pos >= f.size {
+				return n, io.EOF
+			}
+			m := int(min(int64(len(p)-n), min(data, f.size)-pos))
+			clear(p[n : n+m])
+			n += m
+			continue
+		}
+
+		want := len(p) - n
+		if hole, err := seekHole(f.File, pos); err == nil && hole > pos {
+			want = int(min(int64(want), hole-pos))
+		}
+		m, err := f.readAtData(p[n:n+want], pos)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// readAtData reads at the given offset, in aligned blocks in direct mode.
+func (f *file) readAtData(p []byte, offset int64) (int, error) {
+	if !f.direct.Load() {
+		return f.File.ReadAt(p, offset)
+	}
+	if isAligned(p, offset) {
+		return f.readAtDirect(p, offset)
+	}
+
+	// Read the aligned blocks spanning the request and copy out of them
+	buf := getBuffer(f.opts.bufferSize())
+	defer putBuffer(buf)
+	n := 0
+	for n < len(p) {
+		pos := offset + int64(n)
+		start := pos &^ (directAlignment - 1)
+		skip := int(pos - start)
+		want := min(len(*buf), (skip+len(p)-n+directAlignment-1)&^(directAlignment-1))
+		m, err := f.readAtDirect((*buf)[:want], start)
+		if m > skip {
+			n += copy(p[n:], (*buf)[skip:m])
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// readAtDirect reads an aligned request. If the filesystem turns out to
+// reject direct reads, O_DIRECT is cleared and the file is read normally.
+func (f *file) readAtDirect(p []byte, offset int64) (int, error) {
+	n, err := f.File.ReadAt(p, offset)
+	if n == 0 && errors.Is(err, syscall.EINVAL) && disableDirect(f.File) == nil {
+		f.direct.Store(false)
+		fadviseDontNeed(f.File)
+		return f.File.ReadAt(p, offset)
+	}
+	return n, err
+}
+
+// isAligned reports whether a read of p at offset can be done directly.
+func isAligned(p []byte, offset int64) bool {
+	const mask = directAlignment - 1
+	return len(p) > 0 && uintptr(unsafe.Pointer(&p[0]))&mask == 0 && len(p)&mask == 0 && offset&mask == 0
+}
+
+// WriteTo writes the file from its current offset to w.
+func (f *file) WriteTo(w io.Writer) (int64, error) {
+	if f.data == nil {
+		buf := getBuffer(f.opts.bufferSize())
+		defer putBuffer(buf)
+		// Hide os.File.WriteTo, which would copy with a 32 KiB buffer
+		return io.CopyBuffer(w, struct{ io.Reader }{f}, *buf)
+	}
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.writeRange(w, min(offset, f.size), max(f.size-offset, 0))
+	if _, seekErr := f.Seek(offset+n, io.SeekStart); err == nil {
+		err = seekErr
+	}
+	return n, err
+}
+
+// writeRange writes length bytes of the file starting at offset to w.
+func (f *file) writeRange(w io.Writer, offset, length int64) (int64, error) {
+	if f.data != nil && offset+length <= int64(len(f.data)) {
+		return writeMapped(w, f.data[offset:offset+length], f.opts.bufferSize(), f.opts.Limiter)
+	}
+
+	buf := getBuffer(f.opts.bufferSize())
+	defer putBuffer(buf)
+	return io.CopyBuffer(w, io.NewSectionReader(f, offset, length), *buf)
+}
+
+// writeMapped writes mapped data to w in blocks of the buffer size, so that
+// every hasher behind w sees each block while it is still in the CPU cache.
+// If the file is truncated while mapped, reading past its new end faults;
+// the fault is reported as an error instead of crashing.
+func writeMapped(w io.Writer, data []byte, block int, limiter *ratelimit.Limiter) (n int64, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("file changed size while hashing")
+		}
+	}()
+
+	for len(data) > 0 {
+		m, err := w.Write(data[:min(len(data), block)])
+		n += int64(m)
+		limiter.WaitBytes(m)
+		if err != nil {
+			return n, err
+		}
+		data = data[m:]
+	}
+	return n, nil
+}
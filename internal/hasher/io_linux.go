@@ -0,0 +1,89 @@
+//go:build linux && (amd64 || arm64)
+
+package hasher
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ioModesSupported reports whether mmap, drop-cache and direct I/O are
+// implemented on this platform.
+const ioModesSupported = true
+
+// lseek whence values for sparse files from <unistd.h>
+const (
+	seekDataWhence = 3
+	seekHoleWhence = 4
+)
+
+// posix_fadvise advice values from <linux/fadvise.h>
+const (
+	fadvSequential = 2
+	fadvDontNeed   = 4
+)
+
+func fadvise(f *os.File, advice uintptr) {
+	// Advice is only a hint; errors are ignored
+	syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), 0, 0, advice, 0, 0)
+}
+
+// fadviseSequential doubles the kernel's readahead for the file.
+func fadviseSequential(f *os.File) { fadvise(f, fadvSequential) }
+
+// fadviseDontNeed evicts the file's clean pages from the page cache, so that
+// hashing a huge file does not push out everything else.
+func fadviseDontNeed(f *os.File) { fadvise(f, fadvDontNeed) }
+
+// mmap maps size bytes of the file read-only.
+func mmap(f *os.File, size int64) ([]byte, error) {
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file too large to map")
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	syscall.Madvise(data, syscall.MADV_SEQUENTIAL)
+	return data, nil
+}
+
+func munmap(data []byte) { syscall.Munmap(data) }
+
+// openDirect opens a file for reading with O_DIRECT.
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY|syscall.O_DIRECT, 0)
+}
+
+// disableDirect clears O_DIRECT on an open file.
+func disableDirect(f *os.File) error {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_GETFL, 0)
+	if errno != 0 {
+		return errno
+	}
+	_, _, errno = syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_SETFL, flags&^syscall.O_DIRECT)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isSparse reports whether fewer blocks are allocated to a file than its
+// size needs, so that it has holes.
+func isSparse(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && info.Mode().IsRegular() && stat.Blocks*512 < info.Size()
+}
+
+// seekData returns the offset of the first data at or after offset, or
+// ENXIO if there is none.
+func seekData(f *os.File, offset int64) (int64, error) {
+	return syscall.Seek(int(f.Fd()), offset, seekDataWhence)
+}
+
+// seekHole returns the offset of the first hole at or after offset. The end
+// of the file counts as a hole.
+func seekHole(f *os.File, offset int64) (int64, error) {
+	return syscall.Seek(int(f.Fd()), offset, seekHoleWhence)
+}
@@ -0,0 +1,40 @@
+//go:build !linux || !(amd64 || arm64)
+
+package hasher
+
+import (
+	"errors"
+	"os"
+)
+
+// ioModesSupported reports whether mmap, drop-cache and direct I/O are
+// implemented on this platform.
+const ioModesSupported = false
+
+func fadviseSequential(f *os.File) {}
+
+func fadviseDontNeed(f *os.File) {}
+
+func mmap(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func munmap(data []byte) {}
+
+func openDirect(path string) (*os.File, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func disableDirect(f *os.File) error {
+	return errors.ErrUnsupported
+}
+
+func isSparse(info os.FileInfo) bool { return false }
+
+func seekData(f *os.File, offset int64) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func seekHole(f *os.File, offset int64) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,208 @@
+package hasher
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelSegmentSize is the segment size of hashers that can choose one.
+// It is shared so that those hashers can be combined in a single pass, and
+// is a multiple of the BLAKE3 chunk size with a power-of-two chunk count.
+var parallelSegmentSize int64 = 8 * 1024 * 1024
+
+// ParallelHasher is implemented by hashers whose digest can be assembled from
+// independently hashed segments of the input, so that a single large file can
+// be hashed by several workers reading it concurrently with ReadAt.
+type ParallelHasher interface {
+	Hasher
+	// SegmentSize returns the segment length in bytes.
+	// All segments but the last are exactly this long.
+	SegmentSize() int64
+	// NewSegment returns a hash for the segment with the given index.
+	// Its Sum returns an intermediate value to pass to Combine.
+	NewSegment(index int64) hash.Hash
+	// Combine computes the digest of size bytes of input from the
+	// intermediate values of all of its segments, in order.
+	Combine(segments [][]byte, size int64) []byte
+	// MinWorkers returns the number of workers needed for segmented hashing
+	// to be faster than hashing the input sequentially with New.
+	MinWorkers() int
+}
+
+// asParallel returns h as a ParallelHasher, looking through output encodings.
+func asParallel(h Hasher) (ParallelHasher, bool) {
+	if e, ok := h.(encodedHasher); ok {
+		h = e.Hasher
+	}
+	p, ok := h.(ParallelHasher)
+	return p, ok
+}
+
+// Workers is a counting semaphore bounding the goroutines that hash files.
+// Each file holds one worker while it is hashed. Large files split into
+// segments borrow idle workers with TryAcquire, which never competes with
+// files waiting in Acquire, so a few huge files use the whole budget once
+// the small files are done.
+type Workers chan struct{}
+
+// NewWorkers returns a budget of n workers (at least one).
+func NewWorkers(n int) Workers {
+	return make(Workers, max(n, 1))
+}
+
+// Acquire blocks until a worker is available.
+func (w Workers) Acquire() { w <- struct{}{} }
+
+// TryAcquire acquires a worker if one is idle.
+func (w Workers) TryAcquire() bool {
+	select {
+	case w <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release returns a worker to the budget.
+func (w Workers) Release() { <-w }
+
+// HashFileParallel computes hashes for a file like HashFile, but splits large
+// files into segments hashed concurrently by idle workers from the budget.
+// The caller must hold one worker for the file itself.
+//
+// Segmented hashing is used when every hasher supports it with the same
+// segment size, the file spans at least two segments and enough workers are
+// idle for it to pay off; otherwise the file is read sequentially. Digests
+// are identical either way. The file is read as configured by ioOpts.
+func HashFileParallel(path string, hashers []Hasher, workers Workers, ioOpts IOOptions) (map[string]string, error) {
+	if len(hashers) == 0 {
+		return nil, fmt.Errorf("no hashers provided")
+	}
+
+	f, err := openFile(path, ioOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	parallel := make([]ParallelHasher, len(hashers))
+	minWorkers := 1
+	for i, h := range hashers {
+		p, ok := asParallel(h)
+		if !ok || (i > 0 && p.SegmentSize() != parallel[0].SegmentSize()) {
+			return HashReader(f, hashers)
+		}
+		parallel[i] = p
+		minWorkers = max(minWorkers, p.MinWorkers())
+	}
+
+	segmentSize := parallel[0].SegmentSize()
+	segments := (f.size + segmentSize - 1) / segmentSize
+	if workers == nil || segments < max(int64(minWorkers), 2) {
+		return HashReader(f, hashers)
+	}
+
+	// Borrow the helpers needed to beat sequential hashing up front; more are
+	// picked up while hashing as other files finish.
+	helpers := 0
+	for helpers+1 < minWorkers && workers.TryAcquire() {
+		helpers++
+	}
+	if helpers+1 < minWorkers {
+		for ; helpers > 0; helpers-- {
+			workers.Release()
+		}
+		return HashReader(f, hashers)
+	}
+
+	sums := make([][][]byte, len(hashers)) // hasher -> segment -> intermediate value
+	for i := range sums {
+		sums[i] = make([][]byte, segments)
+	}
+
+	var (
+		next     atomic.Int64
+		failed   atomic.Bool
+		errOnce  sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	var work func(helper bool)
+	spawn := func() {
+		wg.Add(1)
+		go work(true)
+	}
+	work = func(helper bool) {
+		defer func() {
+			if helper {
+				workers.Release()
+			}
+			wg.Done()
+		}()
+
+		for {
+			i := next.Add(1) - 1
+			if i >= segments || failed.Load() {
+				return
+			}
+			// Grow while there are segments left that nobody is working on
+			if i+1 < segments && workers.TryAcquire() {
+				spawn()
+			}
+
+			if err := hashSegment(f, parallel, sums, i, segmentSize, f.size); err != nil {
+				failed.Store(true)
+				errOnce.Do(func() { firstErr = err })
+				return
+			}
+		}
+	}
+
+	for ; helpers > 0; helpers-- {
+		spawn()
+	}
+	wg.Add(1)
+	work(false)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	results := make(map[string]string, len(hashers))
+	for i, h := range hashers {
+		results[h.Name()] = h.Encoding().Encode(parallel[i].Combine(sums[i], f.size))
+	}
+	return results, nil
+}
+
+// hashSegment reads segment i of the file and stores its intermediate value
+// for every hasher.
+func hashSegment(f *file, hashers []ParallelHasher, sums [][][]byte, i, segmentSize, size int64) error {
+	offset := i * segmentSize
+	length := min(segmentSize, size-offset)
+
+	hashes := make([]hash.Hash, len(hashers))
+	writers := make([]io.Writer, len(hashers))
+	for j, h := range hashers {
+		hashes[j] = h.NewSegment(i)
+		writers[j] = hashes[j]
+	}
+
+	n, err := f.writeRange(io.MultiWriter(writers...), offset, length)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	if n != length {
+		return fmt.Errorf("failed to read data: file changed size while hashing")
+	}
+
+	for j := range hashers {
+		sums[j][i] = hashes[j].Sum(nil)
+	}
+	return nil
+}
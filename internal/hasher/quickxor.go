@@ -8,10 +8,10 @@ import (
 
 type quickxorHasher struct{}
 
-func (quickxorHasher) Name() string    { return "quickxor" }
-func (quickxorHasher) New() hash.Hash  { return quickxorhash.New() }
-func (quickxorHasher) OutputSize() int { return quickxorhash.Size }
-func (quickxorHasher) IsBase64() bool  { return true } // OneDrive uses base64
+func (quickxorHasher) Name() string       { return "quickxor" }
+func (quickxorHasher) New() hash.Hash     { return quickxorhash.New() }
+func (quickxorHasher) OutputSize() int    { return quickxorhash.Size }
+func (quickxorHasher) Encoding() Encoding { return Base64 } // OneDrive uses base64
 
 func init() {
 	Register(quickxorHasher{})
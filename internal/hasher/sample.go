@@ -0,0 +1,127 @@
+package hasher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Sample selects the parts of a file to hash instead of its whole content,
+// for quick identity checks of huge files. Exactly one mode should be set.
+type Sample struct {
+	// Range mode: hash Length bytes starting at Offset (Length 0 = to the end).
+	Offset int64
+	Length int64
+	// Head/tail mode: hash the first and last HeadTail bytes and the size.
+	HeadTail int64
+	// Samples mode: hash Count evenly spaced blocks of SampleSize bytes
+	// (including the first and last) and the size.
+	Count      int
+	SampleSize int64
+}
+
+// Validate checks that the sample parameters are usable.
+func (s Sample) Validate() error {
+	switch {
+	case s.Offset < 0 || s.Length < 0 || s.HeadTail < 0 || s.Count < 0 || s.SampleSize < 0:
+		return fmt.Errorf("sample sizes must not be negative")
+	case s.Count > 0 && s.SampleSize == 0:
+		return fmt.Errorf("sample size must be positive")
+	case s.HeadTail > 0 && (s.Count > 0 || s.Offset > 0 || s.Length > 0),
+		s.Count > 0 && (s.Offset > 0 || s.Length > 0):
+		return fmt.Errorf("only one of byte range, head/tail and samples may be used")
+	}
+	return nil
+}
+
+// Label describes the sample, e.g. "range=0+1048576", "headtail=65536" or
+// "samples=8x65536". It is appended to algorithm names by Sampled.
+func (s Sample) Label() string {
+	switch {
+	case s.HeadTail > 0:
+		return fmt.Sprintf("headtail=%d", s.HeadTail)
+	case s.Count > 0:
+		return fmt.Sprintf("samples=%dx%d", s.Count, s.SampleSize)
+	case s.Length > 0:
+		return fmt.Sprintf("range=%d+%d", s.Offset, s.Length)
+	default:
+		return fmt.Sprintf("range=%d+", s.Offset)
+	}
+}
+
+// Reader returns a reader over the sampled bytes of r, a file of the given
+// size. Head/tail and samples modes hash the whole file if it is no larger
+// than the sampled bytes, and always end with the size as a big-endian
+// uint64 so that files of different lengths do not collide.
+func (s Sample) Reader(r io.ReaderAt, size int64) io.Reader {
+	var parts []io.Reader
+	section := func(offset, length int64) {
+		parts = append(parts, io.NewSectionReader(r, offset, length))
+	}
+
+	switch {
+	case s.HeadTail > 0:
+		if size <= 2*s.HeadTail {
+			section(0, size)
+		} else {
+			section(0, s.HeadTail)
+			section(size-s.HeadTail, s.HeadTail)
+		}
+	case s.Count > 0:
+		if size <= int64(s.Count)*s.SampleSize {
+			section(0, size)
+		} else if s.Count == 1 {
+			section(0, s.SampleSize)
+		} else {
+			for i := int64(0); i < int64(s.Count); i++ {
+				section(i*(size-s.SampleSize)/int64(s.Count-1), s.SampleSize)
+			}
+		}
+	default:
+		offset := min(s.Offset, size)
+		length := size - offset
+		if s.Length > 0 {
+			length = min(length, s.Length)
+		}
+		section(offset, length)
+		return io.MultiReader(parts...)
+	}
+
+	parts = append(parts, bytes.NewReader(binary.BigEndian.AppendUint64(nil, uint64(size))))
+	return io.MultiReader(parts...)
+}
+
+// sampledHasher labels a hasher whose input is a sample of the file, so that
+// its digests cannot be mistaken for whole-file digests.
+type sampledHasher struct {
+	Hasher
+	label string
+}
+
+func (h sampledHasher) Name() string { return h.Hasher.Name() + "@" + h.label }
+
+// Sampled renames hashers to "algo@label" for hashing a sample of each file.
+// Fuzzy hashers are rejected, since similarity of samples is meaningless.
+func Sampled(hashers []Hasher, sample Sample) ([]Hasher, error) {
+	sampled := make([]Hasher, len(hashers))
+	for i, h := range hashers {
+		if _, ok := h.(FuzzyHasher); ok {
+			return nil, fmt.Errorf("algorithm %s cannot hash file samples", h.Name())
+		}
+		sampled[i] = sampledHasher{Hasher: h, label: sample.Label()}
+	}
+	return sampled, nil
+}
+
+// HashFileSample computes hashes of the sampled bytes of a file, reading it
+// as configured by ioOpts. See Sample.Reader.
+func HashFileSample(path string, hashers []Hasher, sample Sample, ioOpts IOOptions) (map[string]string, error) {
+	f, err := openFile(path, ioOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return HashReader(sample.Reader(f, f.size), hashers)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/binary"
 	"hash"
 	"hash/crc32"
 )
@@ -13,43 +14,112 @@ import (
 
 type md5Hasher struct{}
 
-func (md5Hasher) Name() string    { return "md5" }
-func (md5Hasher) New() hash.Hash  { return md5.New() }
-func (md5Hasher) OutputSize() int { return md5.Size }
-func (md5Hasher) IsBase64() bool  { return false }
+func (md5Hasher) Name() string       { return "md5" }
+func (md5Hasher) New() hash.Hash     { return md5.New() }
+func (md5Hasher) OutputSize() int    { return md5.Size }
+func (md5Hasher) Encoding() Encoding { return Hex }
 
 type sha1Hasher struct{}
 
-func (sha1Hasher) Name() string    { return "sha1" }
-func (sha1Hasher) New() hash.Hash  { return sha1.New() }
-func (sha1Hasher) OutputSize() int { return sha1.Size }
-func (sha1Hasher) IsBase64() bool  { return false }
+func (sha1Hasher) Name() string       { return "sha1" }
+func (sha1Hasher) New() hash.Hash     { return sha1.New() }
+func (sha1Hasher) OutputSize() int    { return sha1.Size }
+func (sha1Hasher) Encoding() Encoding { return Hex }
 
 type sha256Hasher struct{}
 
-func (sha256Hasher) Name() string    { return "sha256" }
-func (sha256Hasher) New() hash.Hash  { return sha256.New() }
-func (sha256Hasher) OutputSize() int { return sha256.Size }
-func (sha256Hasher) IsBase64() bool  { return false }
+func (sha256Hasher) Name() string       { return "sha256" }
+func (sha256Hasher) New() hash.Hash     { return sha256.New() }
+func (sha256Hasher) OutputSize() int    { return sha256.Size }
+func (sha256Hasher) Encoding() Encoding { return Hex }
+
+type sha384Hasher struct{}
+
+func (sha384Hasher) Name() string       { return "sha384" }
+func (sha384Hasher) New() hash.Hash     { return sha512.New384() }
+func (sha384Hasher) OutputSize() int    { return sha512.Size384 }
+func (sha384Hasher) Encoding() Encoding { return Hex }
 
 type sha512Hasher struct{}
 
-func (sha512Hasher) Name() string    { return "sha512" }
-func (sha512Hasher) New() hash.Hash  { return sha512.New() }
-func (sha512Hasher) OutputSize() int { return sha512.Size }
-func (sha512Hasher) IsBase64() bool  { return false }
+func (sha512Hasher) Name() string       { return "sha512" }
+func (sha512Hasher) New() hash.Hash     { return sha512.New() }
+func (sha512Hasher) OutputSize() int    { return sha512.Size }
+func (sha512Hasher) Encoding() Encoding { return Hex }
 
 type crc32Hasher struct{}
 
-func (crc32Hasher) Name() string    { return "crc32" }
-func (crc32Hasher) New() hash.Hash  { return crc32.NewIEEE() }
-func (crc32Hasher) OutputSize() int { return crc32.Size }
-func (crc32Hasher) IsBase64() bool  { return false }
+func (crc32Hasher) Name() string       { return "crc32" }
+func (crc32Hasher) New() hash.Hash     { return crc32.NewIEEE() }
+func (crc32Hasher) OutputSize() int    { return crc32.Size }
+func (crc32Hasher) Encoding() Encoding { return Hex }
+
+// Segment checksums are merged with crc32Combine.
+func (crc32Hasher) SegmentSize() int64               { return parallelSegmentSize }
+func (crc32Hasher) MinWorkers() int                  { return 1 }
+func (crc32Hasher) NewSegment(index int64) hash.Hash { return crc32.NewIEEE() }
+
+func (crc32Hasher) Combine(segments [][]byte, size int64) []byte {
+	var crc uint32
+	for i, s := range segments {
+		length := min(parallelSegmentSize, size-int64(i)*parallelSegmentSize)
+		crc = crc32Combine(crc, binary.BigEndian.Uint32(s), length)
+	}
+	return binary.BigEndian.AppendUint32(nil, crc)
+}
+
+// crc32x2n holds x^(2^n) mod P for the reflected IEEE polynomial.
+var crc32x2n [32]uint32
+
+func init() {
+	p := uint32(1) << 30 // x^1
+	crc32x2n[0] = p
+	for n := 1; n < 32; n++ {
+		p = crc32MulMod(p, p)
+		crc32x2n[n] = p
+	}
+}
+
+// crc32MulMod returns a*b mod P in the reflected bit order used by CRC-32.
+func crc32MulMod(a, b uint32) uint32 {
+	m := uint32(1) << 31
+	var p uint32
+	for {
+		if a&m != 0 {
+			p ^= b
+			if a&(m-1) == 0 {
+				break
+			}
+		}
+		m >>= 1
+		if b&1 != 0 {
+			b = b>>1 ^ crc32.IEEE
+		} else {
+			b >>= 1
+		}
+	}
+	return p
+}
+
+// crc32Combine returns the CRC-32 of two concatenated inputs from their
+// checksums and the length of the second one (as zlib's crc32_combine).
+func crc32Combine(crc1, crc2 uint32, len2 int64) uint32 {
+	// x^(8*len2) mod P
+	x := uint32(1) << 31
+	for k := 3; len2 != 0; k++ {
+		if len2&1 != 0 {
+			x = crc32MulMod(crc32x2n[k&31], x)
+		}
+		len2 >>= 1
+	}
+	return crc32MulMod(x, crc1) ^ crc2
+}
 
 func init() {
 	Register(md5Hasher{})
 	Register(sha1Hasher{})
 	Register(sha256Hasher{})
+	Register(sha384Hasher{})
 	Register(sha512Hasher{})
 	Register(crc32Hasher{})
 }
@@ -10,10 +10,10 @@ import (
 // xxh3Hasher implements 64-bit XXH3
 type xxh3Hasher struct{}
 
-func (xxh3Hasher) Name() string    { return "xxh3" }
-func (xxh3Hasher) New() hash.Hash  { return &xxh3Hash64{h: xxh3.New()} }
-func (xxh3Hasher) OutputSize() int { return 8 }
-func (xxh3Hasher) IsBase64() bool  { return false }
+func (xxh3Hasher) Name() string       { return "xxh3" }
+func (xxh3Hasher) New() hash.Hash     { return &xxh3Hash64{h: xxh3.New()} }
+func (xxh3Hasher) OutputSize() int    { return 8 }
+func (xxh3Hasher) Encoding() Encoding { return Hex }
 
 // xxh3Hash64 wraps xxh3.Hasher to implement hash.Hash
 type xxh3Hash64 struct {
@@ -46,10 +46,10 @@ func (x *xxh3Hash64) BlockSize() int {
 // xxh128Hasher implements 128-bit XXH3
 type xxh128Hasher struct{}
 
-func (xxh128Hasher) Name() string    { return "xxh128" }
-func (xxh128Hasher) New() hash.Hash  { return &xxh3Hash128{h: xxh3.New()} }
-func (xxh128Hasher) OutputSize() int { return 16 }
-func (xxh128Hasher) IsBase64() bool  { return false }
+func (xxh128Hasher) Name() string       { return "xxh128" }
+func (xxh128Hasher) New() hash.Hash     { return &xxh3Hash128{h: xxh3.New()} }
+func (xxh128Hasher) OutputSize() int    { return 16 }
+func (xxh128Hasher) Encoding() Encoding { return Hex }
 
 // xxh3Hash128 wraps xxh3.Hasher to implement hash.Hash for 128-bit output
 type xxh3Hash128 struct {
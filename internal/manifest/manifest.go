@@ -0,0 +1,322 @@
+// Package manifest reads checksum manifests produced by fhash.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+// Entry holds the recorded hashes of a single file.
+type Entry struct {
+	Path      string
+	Size      int64               // File size in bytes (-1 if not recorded)
+	Hashes    map[string]string   // Algorithm name -> hash value
+	ChunkSize int64               // Block size of Chunks (0 if not recorded)
+	Chunks    map[string][]string // Algorithm name -> per-chunk hash values
+}
+
+// Range is a byte range of a file, from Offset for Length bytes.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// Equal reports whether the digest recorded for h matches got, a digest
+// computed by h. Digests are compared as raw bytes, so the manifest may use
+// any encoding accepted by hasher.Decode (hex, base64, base32, multibase...).
+// Fuzzy hash digests are compared as text.
+func (e Entry) Equal(h hasher.Hasher, got string) (bool, error) {
+	recorded, ok := e.Hashes[h.Name()]
+	if !ok {
+		return false, fmt.Errorf("no %s hash recorded for %s", h.Name(), e.Path)
+	}
+	ok, err := equalDigests(h, recorded, got)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", e.Path, err)
+	}
+	return ok, nil
+}
+
+// equalDigests compares a recorded digest with one computed by h.
+func equalDigests(h hasher.Hasher, recorded, got string) (bool, error) {
+	if _, fuzzy := h.(hasher.FuzzyHasher); fuzzy {
+		return recorded == got, nil
+	}
+
+	want, err := hasher.Decode(recorded, h.OutputSize())
+	if err != nil {
+		return false, err
+	}
+	have, err := hasher.Decode(got, h.OutputSize())
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(want, have), nil
+}
+
+// DiffChunks compares the chunk digests recorded for h with got, the chunk
+// digests of a file of the given size computed by h with the same chunk size,
+// and returns the byte ranges that differ. Adjacent differing chunks are
+// merged into one range, and chunks present on only one side (the file grew
+// or shrank) count as differing.
+func (e Entry) DiffChunks(h hasher.Hasher, got []string, size int64) ([]Range, error) {
+	recorded, ok := e.Chunks[h.Name()]
+	if !ok || e.ChunkSize <= 0 {
+		return nil, fmt.Errorf("no %s chunk hashes recorded for %s", h.Name(), e.Path)
+	}
+
+	end := size
+	if e.Size > end {
+		end = e.Size
+	}
+
+	var ranges []Range
+	for i := 0; i < max(len(recorded), len(got)); i++ {
+		same := false
+		if i < len(recorded) && i < len(got) {
+			var err error
+			if same, err = equalDigests(h, recorded[i], got[i]); err != nil {
+				return nil, fmt.Errorf("%s: chunk %d: %w", e.Path, i, err)
+			}
+		}
+		if same {
+			continue
+		}
+
+		offset := int64(i) * e.ChunkSize
+		length := e.ChunkSize
+		if end >= 0 && offset+length > end {
+			length = max(end-offset, 0)
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].Offset+ranges[n-1].Length == offset {
+			ranges[n-1].Length += length
+		} else {
+			ranges = append(ranges, Range{Offset: offset, Length: length})
+		}
+	}
+	return ranges, nil
+}
+
+// Parse reads manifest entries from r.
+// Supported formats are the fhash text output, which is compatible with
+// md5sum/sha256sum ("hash  path" or "algo:hash  path" per line), and JSON
+// Lines. Digests are recorded under the names of hashers, the algorithms of
+// the run reading the manifest, which may be keyed ("hmac-sha256") or
+// sampled ("sha256@headtail=1024"), or of any other known algorithm.
+// Unlabeled text lines are attributed to the hasher if there is only one.
+// Multiple lines for the same path are merged into one entry. Comment lines
+// (including "# ERROR" lines) and JSON error records are ignored.
+func Parse(r io.Reader, hashers []hasher.Hasher) ([]Entry, error) {
+	algos := newAlgorithms(hashers)
+	var entries []Entry
+	index := make(map[string]int)
+
+	add := func(path string, size int64, algo, hash string) {
+		i, ok := index[path]
+		if !ok {
+			i = len(entries)
+			index[path] = i
+			entries = append(entries, Entry{Path: path, Size: -1, Hashes: make(map[string]string)})
+		}
+		if size >= 0 {
+			entries[i].Size = size
+		}
+		if algo != "" {
+			entries[i].Hashes[algo] = hash
+		}
+	}
+
+	addChunks := func(path string, chunkSize int64, chunks map[string]any) error {
+		if chunkSize <= 0 {
+			return fmt.Errorf("chunks without a positive chunk_size")
+		}
+		add(path, -1, "", "")
+		e := &entries[index[path]]
+		e.ChunkSize = chunkSize
+		for algo, list := range chunks {
+			values, ok := list.([]any)
+			if !ok {
+				return fmt.Errorf("chunks for %s is not an array", algo)
+			}
+			digests := make([]string, len(values))
+			for i, v := range values {
+				if digests[i], ok = v.(string); !ok {
+					return fmt.Errorf("chunks for %s contains a non-string value", algo)
+				}
+			}
+			if e.Chunks == nil {
+				e.Chunks = make(map[string][]string)
+			}
+			name, ok := algos.lookup(algo)
+			if !ok {
+				name = strings.ToLower(algo)
+			}
+			e.Chunks[name] = digests
+		}
+		return nil
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "{") {
+			if err := parseJSONLine(line, algos, add, addChunks); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			continue
+		}
+
+		algo, hash, path, err := parseTextLine(line, algos)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		add(path, -1, algo, hash)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ParseFile reads manifest entries from a file. See Parse.
+func ParseFile(path string, hashers []hasher.Hasher) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f, hashers)
+}
+
+// algorithms resolves the algorithm names used in a manifest.
+type algorithms struct {
+	names       map[string]string // Lower-case name -> name of a hasher of the run
+	defaultAlgo string            // Algorithm of unlabeled lines ("" if ambiguous)
+}
+
+func newAlgorithms(hashers []hasher.Hasher) algorithms {
+	a := algorithms{names: make(map[string]string, len(hashers))}
+	for _, h := range hashers {
+		a.names[strings.ToLower(h.Name())] = h.Name()
+	}
+	if len(hashers) == 1 {
+		a.defaultAlgo = hashers[0].Name()
+	}
+	return a
+}
+
+// lookup returns the name under which digests labeled name are recorded,
+// or false if name is neither a hasher of the run nor a known algorithm.
+func (a algorithms) lookup(name string) (string, bool) {
+	if known, ok := a.names[strings.ToLower(name)]; ok {
+		return known, true
+	}
+	if _, ok := hasher.Get(name); ok {
+		return strings.ToLower(name), true
+	}
+	return "", false
+}
+
+// parseTextLine parses "hash  path", "algo:hash  path" or the binary-mode
+// form "hash *path" used by md5sum.
+func parseTextLine(line string, algos algorithms) (algo, hash, path string, err error) {
+	hash, path, ok := strings.Cut(line, "  ")
+	if !ok {
+		hash, path, ok = strings.Cut(line, " *")
+	}
+	if !ok || hash == "" || path == "" {
+		return "", "", "", fmt.Errorf("invalid manifest line: %q", line)
+	}
+
+	// Only treat the prefix as a label if it names a known algorithm, since
+	// some digests (e.g. ssdeep) contain colons themselves.
+	if label, rest, found := strings.Cut(hash, ":"); found {
+		if name, known := algos.lookup(label); known {
+			return name, rest, path, nil
+		}
+	}
+
+	if algos.defaultAlgo == "" {
+		return "", "", "", fmt.Errorf("unlabeled hash requires a single algorithm: %q", line)
+	}
+	return algos.defaultAlgo, hash, path, nil
+}
+
+// parseJSONLine parses a JSON Lines record with "path", optional "size",
+// one string field per algorithm and optional "chunk_size" and "chunks".
+func parseJSONLine(line string, algos algorithms, add func(path string, size int64, algo, hash string), addChunks func(path string, chunkSize int64, chunks map[string]any) error) error {
+	var data map[string]any
+	if err := json.Unmarshal([]byte(line), &data); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, isError := data["error"]; isError {
+		return nil
+	}
+
+	path, ok := data["path"].(string)
+	if !ok || path == "" {
+		return fmt.Errorf("missing path")
+	}
+
+	size := int64(-1)
+	if v, ok := data["size"].(float64); ok {
+		size = int64(v)
+	}
+
+	if chunks, ok := data["chunks"].(map[string]any); ok {
+		chunkSize, _ := data["chunk_size"].(float64)
+		if err := addChunks(path, int64(chunkSize), chunks); err != nil {
+			return err
+		}
+	}
+
+	added := false
+	for key, value := range data {
+		hash, ok := value.(string)
+		if !ok {
+			continue
+		}
+		name, known := algos.lookup(key)
+		if !known {
+			continue
+		}
+		add(path, size, name, hash)
+		added = true
+	}
+	if !added && !isHashedFile(data) {
+		return nil // Recorded symlinks and skipped files have no hashes to check
+	}
+	if !added {
+		add(path, size, "", "")
+	}
+	return nil
+}
+
+// isHashedFile reports whether a JSON record is of a file that was hashed,
+// rather than a recorded symlink, a skipped special file or an excluded file.
+func isHashedFile(data map[string]any) bool {
+	if typ, typed := data["type"]; typed && typ != "file" {
+		return false
+	}
+	if _, skipped := data["skipped"]; skipped {
+		return false
+	}
+	_, excluded := data["excluded"]
+	return !excluded
+}
@@ -0,0 +1,291 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+// hashersOf returns the hashers of a comma-separated list of names, or nil
+// for an empty list.
+func hashersOf(t *testing.T, names string) []hasher.Hasher {
+	t.Helper()
+	if names == "" {
+		return nil
+	}
+	hashers, err := hasher.Parse(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hashers
+}
+
+func TestParse_Text(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"aabbccdd  file1.txt",
+		"11223344 *file2.bin",
+		"",
+		"# ERROR: missing.txt: file not found",
+	}, "\n")
+
+	entries, err := Parse(strings.NewReader(input), hashersOf(t, "md5"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Path != "file1.txt" || entries[0].Hashes["md5"] != "aabbccdd" {
+		t.Errorf("entry 0 = %+v", entries[0])
+	}
+	if entries[1].Path != "file2.bin" || entries[1].Hashes["md5"] != "11223344" {
+		t.Errorf("entry 1 = %+v", entries[1])
+	}
+	if entries[0].Size != -1 {
+		t.Errorf("size = %d, want -1", entries[0].Size)
+	}
+}
+
+func TestParse_MultiAlgorithmText(t *testing.T) {
+	input := strings.Join([]string{
+		"md5:aabbccdd  dir/file with spaces.txt",
+		"sha256:11223344  dir/file with spaces.txt",
+		"ssdeep:3:AXGBicFlgVNhBGcL6wCrFQEv:AXGHsNhxLsr2C  other.txt",
+	}, "\n")
+
+	entries, err := Parse(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Path != "dir/file with spaces.txt" {
+		t.Errorf("path = %q", entries[0].Path)
+	}
+	if entries[0].Hashes["md5"] != "aabbccdd" || entries[0].Hashes["sha256"] != "11223344" {
+		t.Errorf("hashes = %v", entries[0].Hashes)
+	}
+	if got := entries[1].Hashes["ssdeep"]; got != "3:AXGBicFlgVNhBGcL6wCrFQEv:AXGHsNhxLsr2C" {
+		t.Errorf("ssdeep = %q", got)
+	}
+}
+
+func TestParse_UnlabeledColonDigest(t *testing.T) {
+	// A single-algorithm ssdeep manifest has colons but no label
+	input := "3:AXGBicFlgVNhBGcL6wCrFQEv:AXGHsNhxLsr2C  a.txt\n"
+
+	entries, err := Parse(strings.NewReader(input), hashersOf(t, "ssdeep"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := entries[0].Hashes["ssdeep"]; got != "3:AXGBicFlgVNhBGcL6wCrFQEv:AXGHsNhxLsr2C" {
+		t.Errorf("ssdeep = %q", got)
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	input := strings.Join([]string{
+		`{"path":"a.txt","size":100,"type":"file","md5":"aabbccdd","sha256":"11223344"}`,
+		`{"path":"missing.txt","error":"file not found"}`,
+		`{"path":"link.txt","size":5,"type":"symlink","target":"a.txt"}`,
+		`{"path":"fifo","size":0,"type":"fifo","skipped":true}`,
+	}, "\n")
+
+	entries, err := Parse(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Path != "a.txt" || e.Size != 100 {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Hashes["md5"] != "aabbccdd" || e.Hashes["sha256"] != "11223344" {
+		t.Errorf("hashes = %v", e.Hashes)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		algo  string
+	}{
+		{name: "unlabeled without default", input: "aabbccdd  a.txt", algo: ""},
+		{name: "no separator", input: "aabbccdd", algo: "md5"},
+		{name: "invalid JSON", input: `{"path":`, algo: ""},
+		{name: "JSON without path", input: `{"md5":"aa"}`, algo: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.input), hashersOf(t, tt.algo)); err == nil {
+				t.Errorf("Parse(%q) expected error, got nil", tt.input)
+			}
+		})
+	}
+}
+
+func TestEntry_Equal(t *testing.T) {
+	sha256, _ := hasher.Get("sha256")
+	// sha256("hello world") in several encodings
+	hexDigest := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	encodings := []string{
+		hexDigest,
+		strings.ToUpper(hexDigest),
+		"uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=",
+		"uU0nuZNNPgilLlLX2n2r-sSE7-N6U4DukIj3rOLvzek",
+		"XFGSPOMTJU7ARJJOKLL5U7NL7LCIJ37DPJJYB3UQRD32ZYXPZXUQ====",
+		"bxfgspomtju7arjjokll5u7nl7lcij37dpjjyb3uqrd32zyxpzxuq",
+	}
+
+	for _, recorded := range encodings {
+		e := Entry{Path: "hw", Hashes: map[string]string{"sha256": recorded}}
+		ok, err := e.Equal(sha256, hexDigest)
+		if err != nil {
+			t.Errorf("Equal(%q) error: %v", recorded, err)
+			continue
+		}
+		if !ok {
+			t.Errorf("Equal(%q) = false, want true", recorded)
+		}
+	}
+
+	e := Entry{Path: "hw", Hashes: map[string]string{"sha256": hexDigest}}
+	if ok, _ := e.Equal(sha256, strings.Repeat("0", 64)); ok {
+		t.Error("Equal(different digest) = true, want false")
+	}
+
+	md5, _ := hasher.Get("md5")
+	if _, err := e.Equal(md5, "aabbccdd"); err == nil {
+		t.Error("Equal(unrecorded algorithm) expected error, got nil")
+	}
+}
+
+func TestParse_JSONChunks(t *testing.T) {
+	input := `{"path":"a.bin","size":2500,"sha256":"aa","chunk_size":1024,"chunks":{"sha256":["c0","c1","c2"]}}`
+
+	entries, err := Parse(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	e := entries[0]
+	if e.ChunkSize != 1024 {
+		t.Errorf("chunk size = %d, want 1024", e.ChunkSize)
+	}
+	if got := strings.Join(e.Chunks["sha256"], ","); got != "c0,c1,c2" {
+		t.Errorf("chunks = %v", e.Chunks)
+	}
+
+	if _, err := Parse(strings.NewReader(`{"path":"a","chunks":{"sha256":["c0"]}}`), nil); err == nil {
+		t.Error("chunks without chunk_size: expected error, got nil")
+	}
+}
+
+func TestEntry_DiffChunks(t *testing.T) {
+	md5, _ := hasher.Get("md5")
+	digest := func(c byte) string { return strings.Repeat(string(c), 32) }
+
+	e := Entry{
+		Path:      "a.bin",
+		Size:      4500,
+		ChunkSize: 1024,
+		Chunks: map[string][]string{
+			"md5": {digest('0'), digest('1'), digest('2'), digest('3'), digest('4')},
+		},
+	}
+
+	tests := []struct {
+		name string
+		got  []string
+		size int64
+		want []Range
+	}{
+		{
+			name: "identical",
+			got:  []string{digest('0'), digest('1'), digest('2'), digest('3'), digest('4')},
+			size: 4500,
+			want: nil,
+		},
+		{
+			name: "adjacent chunks merged",
+			got:  []string{digest('0'), digest('a'), digest('b'), digest('3'), digest('c')},
+			size: 4500,
+			want: []Range{{Offset: 1024, Length: 2048}, {Offset: 4096, Length: 404}},
+		},
+		{
+			name: "truncated",
+			got:  []string{digest('0'), digest('1'), digest('2')},
+			size: 3072,
+			want: []Range{{Offset: 3072, Length: 1428}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.DiffChunks(md5, tt.got, tt.size)
+			if err != nil {
+				t.Fatalf("DiffChunks failed: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("DiffChunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	sha1, _ := hasher.Get("sha1")
+	if _, err := e.DiffChunks(sha1, nil, 0); err == nil {
+		t.Error("DiffChunks(unrecorded algorithm) expected error, got nil")
+	}
+}
+
+func TestParse_KeyedAndSampledNames(t *testing.T) {
+	keyed, err := hasher.Keyed(hashersOf(t, "sha256"), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampled, err := hasher.Sampled(hashersOf(t, "md5"), hasher.Sample{HeadTail: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashers := append(keyed, sampled...)
+
+	input := strings.Join([]string{
+		"hmac-sha256:aabbccdd  a.txt",
+		"md5@headtail=1024:11223344  a.txt",
+		`{"path":"b.txt","size":3,"type":"file","hmac-sha256":"eeff","md5@headtail=1024":"5566"}`,
+	}, "\n")
+	entries, err := Parse(strings.NewReader(input), hashers)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if got := entries[0].Hashes; got["hmac-sha256"] != "aabbccdd" || got["md5@headtail=1024"] != "11223344" {
+		t.Errorf("text hashes = %v", got)
+	}
+	if got := entries[1].Hashes; got["hmac-sha256"] != "eeff" || got["md5@headtail=1024"] != "5566" {
+		t.Errorf("JSON hashes = %v", got)
+	}
+
+	// Without the keyed hasher, the label is not an algorithm
+	if _, err := Parse(strings.NewReader("hmac-sha256:aabbccdd  a.txt"), nil); err == nil {
+		t.Error("Parse(unknown label) expected error, got nil")
+	}
+
+	// A hashed file is kept even without usable hashes, so checking it fails
+	entries, err = Parse(strings.NewReader(`{"path":"b.txt","type":"file","hmac-sha256":"eeff"}`), hashersOf(t, "sha256"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(entries) != 1 || len(entries[0].Hashes) != 0 {
+		t.Errorf("entries = %+v, want b.txt without hashes", entries)
+	}
+}
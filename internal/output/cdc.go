@@ -0,0 +1,107 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// formatCDCText formats content-defined chunks as comment lines following
+// the file's hash lines, so the output stays md5sum-compatible:
+// "# cdc <offset> <length> <hash>" (or "algo:hash ..." for several algorithms).
+func formatCDCText(result *scanner.Result, algorithms []string) string {
+	if len(result.CDCChunks) == 0 {
+		return ""
+	}
+
+	// Fuzzy algorithms have no chunk hashes
+	algos := make([]string, 0, len(algorithms))
+	for _, algo := range algorithms {
+		if _, ok := result.CDCChunks[0].Hashes[algo]; ok {
+			algos = append(algos, algo)
+		}
+	}
+	sort.Strings(algos)
+
+	var b strings.Builder
+	for _, c := range result.CDCChunks {
+		fmt.Fprintf(&b, "\n# cdc %d %d", c.Offset, c.Length)
+		for _, algo := range algos {
+			if len(algos) == 1 {
+				fmt.Fprintf(&b, " %s", c.Hashes[algo])
+			} else {
+				fmt.Fprintf(&b, " %s:%s", algo, c.Hashes[algo])
+			}
+		}
+	}
+	return b.String()
+}
+
+// CDCSummary accumulates deduplication statistics over the content-defined
+// chunks of all scanned files. Chunks are identified by their length and
+// digest for a single algorithm.
+type CDCSummary struct {
+	Algorithm    string
+	Chunks       int64
+	UniqueChunks int64
+	Bytes        int64
+	UniqueBytes  int64
+
+	seen map[string]struct{}
+}
+
+// NewCDCSummary creates a summary identifying chunks by the given algorithm.
+func NewCDCSummary(algorithm string) *CDCSummary {
+	return &CDCSummary{Algorithm: algorithm, seen: make(map[string]struct{})}
+}
+
+// Add counts the chunks of a result. Hardlinks of a file already counted
+// are skipped, since their bytes are stored only once.
+func (s *CDCSummary) Add(result *scanner.Result) {
+	if result.LinkOf != "" {
+		return
+	}
+	for _, c := range result.CDCChunks {
+		s.Chunks++
+		s.Bytes += int64(c.Length)
+
+		key := fmt.Sprintf("%d:%s", c.Length, c.Hashes[s.Algorithm])
+		if _, dup := s.seen[key]; dup {
+			continue
+		}
+		s.seen[key] = struct{}{}
+		s.UniqueChunks++
+		s.UniqueBytes += int64(c.Length)
+	}
+}
+
+// Ratio returns the deduplication ratio (total bytes / unique bytes).
+func (s *CDCSummary) Ratio() float64 {
+	if s.UniqueBytes == 0 {
+		return 1
+	}
+	return float64(s.Bytes) / float64(s.UniqueBytes)
+}
+
+// Format formats the summary as a comment line, or as a JSON object
+// {"cdc_summary": {...}} when asJSON is set.
+func (s *CDCSummary) Format(asJSON bool) string {
+	if asJSON {
+		data := map[string]interface{}{
+			"cdc_summary": map[string]interface{}{
+				"chunks":        s.Chunks,
+				"unique_chunks": s.UniqueChunks,
+				"bytes":         s.Bytes,
+				"unique_bytes":  s.UniqueBytes,
+				"dedup_ratio":   s.Ratio(),
+			},
+		}
+		b, _ := json.Marshal(data)
+		return string(b)
+	}
+	return fmt.Sprintf("# cdc summary: %d chunks (%d unique), %d bytes (%d unique), dedup ratio %.2f",
+		s.Chunks, s.UniqueChunks, s.Bytes, s.UniqueBytes, s.Ratio())
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// FormatDryRun formats a result of a dry run. Files that would be hashed
+// are written as "size  path", or as the JSON object of JSONFormatter
+// without hashes; symlinks, skipped special files and excluded files are
+// written as by the other formatters.
+func FormatDryRun(result *scanner.Result, asJSON bool) string {
+	if asJSON {
+		return NewJSONFormatter().Format(result)
+	}
+	if line, ok := formatUnhashed(result); ok {
+		return line
+	}
+	return fmt.Sprintf("%d  %s", result.Size, result.Path)
+}
+
+// DryRunSummary counts the files a dry run would hash and those it would
+// not.
+type DryRunSummary struct {
+	Files    int64 // Files that would be hashed
+	Bytes    int64 // Their total size, counting hardlinked files once
+	Excluded int64 // Files and directories left out by the filters
+	Skipped  int64 // Symlinks and special files that would not be hashed
+	Errors   int64
+}
+
+// NewDryRunSummary creates an empty summary.
+func NewDryRunSummary() *DryRunSummary {
+	return &DryRunSummary{}
+}
+
+// Add counts a result.
+func (d *DryRunSummary) Add(result *scanner.Result) {
+	switch {
+	case result.IsError():
+		d.Errors++
+	case result.Excluded != "":
+		d.Excluded++
+	case result.Skipped || result.Type == scanner.TypeSymlink:
+		d.Skipped++
+	default:
+		d.Files++
+		if result.LinkOf == "" {
+			d.Bytes += result.Size // Other links reuse the hashes of the first
+		}
+	}
+}
+
+// Format formats the summary as a comment line
+// "# dry run: 3 files, 1024 bytes (2 excluded, 1 skipped, 0 errors)", or as
+// a JSON object {"dry_run": {...}} when asJSON is set.
+func (d *DryRunSummary) Format(asJSON bool) string {
+	if asJSON {
+		data := map[string]interface{}{
+			"dry_run": map[string]interface{}{
+				"files":    d.Files,
+				"bytes":    d.Bytes,
+				"excluded": d.Excluded,
+				"skipped":  d.Skipped,
+				"errors":   d.Errors,
+			},
+		}
+		b, _ := json.Marshal(data)
+		return string(b)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# dry run: %d files, %d bytes", d.Files, d.Bytes)
+	fmt.Fprintf(&b, " (%d excluded, %d skipped, %d errors)", d.Excluded, d.Skipped, d.Errors)
+	return b.String()
+}
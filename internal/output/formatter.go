@@ -12,3 +12,10 @@ type Formatter interface {
 	// FormatError formats an error result.
 	FormatError(result *scanner.Result) string
 }
+
+// Finisher is implemented by formatters that buffer results and produce
+// their output only after all results have been formatted.
+type Finisher interface {
+	// Finish returns the buffered output.
+	Finish() string
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// HardlinkReport collects the files whose hashes were reused from another
+// hardlink of the same file, grouped by the link that was hashed.
+type HardlinkReport struct {
+	Links int64 // Links whose hashes were reused
+	Bytes int64 // Bytes not read again
+
+	groups map[string]*hardlinkGroup
+}
+
+type hardlinkGroup struct {
+	size  int64
+	links []string
+}
+
+// NewHardlinkReport creates an empty report.
+func NewHardlinkReport() *HardlinkReport {
+	return &HardlinkReport{groups: make(map[string]*hardlinkGroup)}
+}
+
+// Add records a result if it is a hardlink of an earlier file.
+func (r *HardlinkReport) Add(result *scanner.Result) {
+	if result.LinkOf == "" {
+		return
+	}
+	g, ok := r.groups[result.LinkOf]
+	if !ok {
+		g = &hardlinkGroup{size: result.Size}
+		r.groups[result.LinkOf] = g
+	}
+	g.links = append(g.links, result.Path)
+	r.Links++
+	r.Bytes += result.Size
+}
+
+// Format formats the groups as comment lines "# hardlink <link> => <path>"
+// followed by a summary line, or as a JSON object {"hardlinks": {...}} when
+// asJSON is set. Groups and links are sorted by path.
+func (r *HardlinkReport) Format(asJSON bool) string {
+	paths := make([]string, 0, len(r.groups))
+	for path, g := range r.groups {
+		sort.Strings(g.links)
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	if asJSON {
+		groups := make([]map[string]interface{}, len(paths))
+		for i, path := range paths {
+			g := r.groups[path]
+			groups[i] = map[string]interface{}{"path": path, "size": g.size, "links": g.links}
+		}
+		data := map[string]interface{}{
+			"hardlinks": map[string]interface{}{
+				"groups":      groups,
+				"links":       r.Links,
+				"bytes_saved": r.Bytes,
+			},
+		}
+		b, _ := json.Marshal(data)
+		return string(b)
+	}
+
+	var b strings.Builder
+	for _, path := range paths {
+		for _, link := range r.groups[path].links {
+			fmt.Fprintf(&b, "# hardlink %s => %s\n", link, path)
+		}
+	}
+	fmt.Fprintf(&b, "# hardlink summary: %d groups, %d links, %d bytes not read again", len(paths), r.Links, r.Bytes)
+	return b.String()
+}
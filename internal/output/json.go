@@ -7,7 +7,11 @@ import (
 )
 
 // JSONFormatter formats results as JSON Lines (NDJSON).
-type JSONFormatter struct{}
+type JSONFormatter struct {
+	// ChunkSize is the block size of per-chunk hashes, reported alongside
+	// them as "chunk_size" when a result has chunk lists.
+	ChunkSize int64
+}
 
 // NewJSONFormatter creates a new JSON formatter.
 func NewJSONFormatter() *JSONFormatter {
@@ -33,12 +37,46 @@ func (f *JSONFormatter) Format(result *scanner.Result) string {
 	data := make(map[string]interface{})
 	data["path"] = result.Path
 	data["size"] = result.Size
+	if result.Type != "" {
+		data["type"] = result.Type
+	}
+	if result.Type == scanner.TypeSymlink {
+		data["target"] = result.Target
+	}
+	if result.Skipped {
+		data["skipped"] = true
+	}
+	if result.Excluded != "" {
+		data["excluded"] = result.Excluded
+	}
+	if result.LinkOf != "" {
+		data["link_of"] = result.LinkOf
+	}
 
 	// Flatten hashes into the top level
 	for algo, hash := range result.Hashes {
 		data[algo] = hash
 	}
 
+	// Chunk lists as {"chunks": {"algo": ["...", ...]}}
+	if result.Chunks != nil {
+		data["chunk_size"] = f.ChunkSize
+		data["chunks"] = result.Chunks
+	}
+
+	// Content-defined chunks as {"cdc_chunks": [{"offset", "length", "algo"}]}
+	if result.CDCChunks != nil {
+		chunks := make([]map[string]interface{}, len(result.CDCChunks))
+		for i, c := range result.CDCChunks {
+			chunk := map[string]interface{}{"offset": c.Offset, "length": c.Length}
+			for algo, hash := range c.Hashes {
+				chunk[algo] = hash
+			}
+			chunks[i] = chunk
+		}
+		data["cdc_chunks"] = chunks
+	}
+
 	b, _ := json.Marshal(data)
 	return string(b)
 }
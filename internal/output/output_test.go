@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/Virace/fast-hasher/internal/hasher"
 	"github.com/Virace/fast-hasher/internal/scanner"
 )
 
@@ -48,6 +49,25 @@ func TestTextFormatter_Format_MultipleAlgorithms(t *testing.T) {
 	}
 }
 
+func TestTextFormatter_Format_Labeled(t *testing.T) {
+	f := NewTextFormatter([]string{"hmac-sha256"})
+	f.Labeled = true
+	result := &scanner.Result{
+		Path: "test.txt",
+		Size: 100,
+		Hashes: map[string]string{
+			"hmac-sha256": "abc123def456",
+		},
+	}
+
+	got := f.Format(result)
+	expected := "hmac-sha256:abc123def456  test.txt"
+
+	if got != expected {
+		t.Errorf("Format() = %q, want %q", got, expected)
+	}
+}
+
 func TestTextFormatter_FormatError(t *testing.T) {
 	f := NewTextFormatter([]string{"sha256"})
 	result := &scanner.Result{
@@ -97,6 +117,136 @@ func TestJSONFormatter_Format(t *testing.T) {
 	}
 }
 
+func TestFormat_Unhashed(t *testing.T) {
+	result := &scanner.Result{Path: "link.txt", Size: 5, Type: scanner.TypeSymlink, Target: "a.txt"}
+
+	if got, want := NewTextFormatter([]string{"sha256"}).Format(result), "# SYMLINK: link.txt -> a.txt"; got != want {
+		t.Errorf("text Format() = %q, want %q", got, want)
+	}
+	fifo := &scanner.Result{Path: "fifo", Type: scanner.TypeFIFO, Skipped: true}
+	if got, want := NewTextFormatter([]string{"sha256"}).Format(fifo), "# SKIPPED: fifo (fifo)"; got != want {
+		t.Errorf("text Format() = %q, want %q", got, want)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(NewJSONFormatter().Format(result)), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if data["type"] != "symlink" || data["target"] != "a.txt" || data["path"] != "link.txt" {
+		t.Errorf("JSON = %v", data)
+	}
+}
+
+func TestJSONFormatter_Format_Keyed(t *testing.T) {
+	f := NewJSONFormatter()
+	result := &scanner.Result{
+		Path: "test.txt",
+		Size: 100,
+		Hashes: map[string]string{
+			"hmac-sha256": "11223344",
+		},
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(f.Format(result)), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if data["hmac-sha256"] != "11223344" {
+		t.Errorf("hmac-sha256 = %v, want 11223344", data["hmac-sha256"])
+	}
+	if _, ok := data["sha256"]; ok {
+		t.Error("keyed hash must not be reported as plain sha256")
+	}
+}
+
+func TestJSONFormatter_Format_Chunks(t *testing.T) {
+	f := NewJSONFormatter()
+	f.ChunkSize = 4
+	result := &scanner.Result{
+		Path:   "test.txt",
+		Size:   6,
+		Hashes: map[string]string{"md5": "aabbccdd"},
+		Chunks: map[string][]string{"md5": {"11", "22"}},
+	}
+
+	var data struct {
+		ChunkSize int64               `json:"chunk_size"`
+		Chunks    map[string][]string `json:"chunks"`
+		MD5       string              `json:"md5"`
+	}
+	if err := json.Unmarshal([]byte(f.Format(result)), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if data.ChunkSize != 4 || data.MD5 != "aabbccdd" {
+		t.Errorf("got %+v", data)
+	}
+	if got := strings.Join(data.Chunks["md5"], ","); got != "11,22" {
+		t.Errorf("chunks = %v, want [11 22]", data.Chunks["md5"])
+	}
+
+	// Without chunk lists the output has no chunk fields
+	result.Chunks = nil
+	if got := f.Format(result); strings.Contains(got, "chunk") {
+		t.Errorf("Format() = %s, want no chunk fields", got)
+	}
+}
+
+func TestFormatter_CDCChunks(t *testing.T) {
+	result := &scanner.Result{
+		Path:   "a.bin",
+		Size:   300,
+		Hashes: map[string]string{"sha256": "aa"},
+		CDCChunks: []hasher.CDCChunk{
+			{Offset: 0, Length: 100, Hashes: map[string]string{"sha256": "11"}},
+			{Offset: 100, Length: 200, Hashes: map[string]string{"sha256": "22"}},
+		},
+	}
+
+	text := NewTextFormatter([]string{"sha256"}).Format(result)
+	want := "aa  a.bin\n# cdc 0 100 11\n# cdc 100 200 22"
+	if text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+
+	var data struct {
+		Chunks []struct {
+			Offset int64  `json:"offset"`
+			Length int    `json:"length"`
+			SHA256 string `json:"sha256"`
+		} `json:"cdc_chunks"`
+	}
+	if err := json.Unmarshal([]byte(NewJSONFormatter().Format(result)), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(data.Chunks) != 2 || data.Chunks[1].Offset != 100 || data.Chunks[1].Length != 200 || data.Chunks[1].SHA256 != "22" {
+		t.Errorf("cdc_chunks = %+v", data.Chunks)
+	}
+}
+
+func TestCDCSummary(t *testing.T) {
+	chunk := func(length int, hash string) hasher.CDCChunk {
+		return hasher.CDCChunk{Length: length, Hashes: map[string]string{"sha256": hash}}
+	}
+	s := NewCDCSummary("sha256")
+	s.Add(&scanner.Result{CDCChunks: []hasher.CDCChunk{chunk(100, "a"), chunk(200, "b")}})
+	s.Add(&scanner.Result{CDCChunks: []hasher.CDCChunk{chunk(100, "a"), chunk(100, "c")}})
+
+	if s.Chunks != 4 || s.UniqueChunks != 3 || s.Bytes != 500 || s.UniqueBytes != 400 {
+		t.Errorf("summary = %+v", s)
+	}
+	if s.Ratio() != 1.25 {
+		t.Errorf("Ratio() = %v, want 1.25", s.Ratio())
+	}
+
+	var data map[string]map[string]float64
+	if err := json.Unmarshal([]byte(s.Format(true)), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if data["cdc_summary"]["unique_bytes"] != 400 {
+		t.Errorf("JSON summary = %v", data)
+	}
+}
+
 func TestJSONFormatter_FormatError(t *testing.T) {
 	f := NewJSONFormatter()
 	result := &scanner.Result{
@@ -118,3 +268,133 @@ func TestJSONFormatter_FormatError(t *testing.T) {
 		t.Errorf("error = %v, want 'file not found'", data["error"])
 	}
 }
+
+// sha384 and sha256 of "alert('Hello, world.');" (the example from the SRI spec)
+const (
+	sriSHA384Hex = "1fc05187c8f8f0ef6861ab5fbb9019ceae80f5120d8593b91f5e9d4199e02bb4fad9e9bc314b7514b9b9dadf9e5fac4e"
+	sriSHA256Hex = "ab39cb72c44ec7818008fd9d9b4502282cc21be1e267582eaba6590e86ff4e78"
+	sriSHA384    = "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO"
+)
+
+func TestSRIFormatter_Format(t *testing.T) {
+	f := NewSRIFormatter([]string{"sha384"})
+	result := &scanner.Result{
+		Path:   "app.js",
+		Hashes: map[string]string{"sha384": sriSHA384Hex},
+	}
+
+	want := sriSHA384 + "  app.js"
+	if got := f.Format(result); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestSRIFormatter_Format_MultipleAlgorithms(t *testing.T) {
+	f := NewSRIFormatter([]string{"sha256", "sha384"})
+	result := &scanner.Result{
+		Path: "app.js",
+		Hashes: map[string]string{
+			"sha256": sriSHA256Hex,
+			// Already base64, as produced with --encoding base64
+			"sha384": strings.TrimPrefix(sriSHA384, "sha384-"),
+		},
+	}
+
+	want := "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng= " + sriSHA384 + "  app.js"
+	if got := f.Format(result); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestSRIMapFormatter(t *testing.T) {
+	f := NewSRIMapFormatter([]string{"sha384"}, "dist")
+	for _, path := range []string{"dist/app.js", "dist/assets/vendor.js"} {
+		result := &scanner.Result{
+			Path:   path,
+			Hashes: map[string]string{"sha384": sriSHA384Hex},
+		}
+		if got := f.Format(result); got != "" {
+			t.Errorf("Format() = %q, want empty", got)
+		}
+	}
+
+	var data map[string]string
+	if err := json.Unmarshal([]byte(f.Finish()), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("got %d entries, want 2: %v", len(data), data)
+	}
+	if data["app.js"] != sriSHA384 || data["assets/vendor.js"] != sriSHA384 {
+		t.Errorf("map = %v", data)
+	}
+}
+
+func TestHardlinkReport(t *testing.T) {
+	r := NewHardlinkReport()
+	r.Add(&scanner.Result{Path: "a", Size: 10})
+	r.Add(&scanner.Result{Path: "c", Size: 10, LinkOf: "a"})
+	r.Add(&scanner.Result{Path: "b", Size: 10, LinkOf: "a"})
+
+	want := "# hardlink b => a\n# hardlink c => a\n# hardlink summary: 1 groups, 2 links, 20 bytes not read again"
+	if got := r.Format(false); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	var data map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(r.Format(true)), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if data["hardlinks"]["links"].(float64) != 2 || data["hardlinks"]["bytes_saved"].(float64) != 20 {
+		t.Errorf("JSON report = %v", data)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	results := []*scanner.Result{
+		{Path: "a.txt", Size: 100, Type: scanner.TypeFile},
+		{Path: "b.bin", Size: 50, Type: scanner.TypeFile},
+		{Path: "b.link", Size: 50, Type: scanner.TypeFile, LinkOf: "b.bin"},
+		{Path: "big.iso", Size: 1 << 30, Type: scanner.TypeFile, Excluded: "larger than 1048576 bytes"},
+		{Path: "build", Type: scanner.TypeDir, Excluded: "ignored by \"build/\" in .gitignore"},
+		{Path: "fifo", Type: scanner.TypeFIFO, Skipped: true},
+		{Path: "gone", Error: errors.New("file not found")},
+	}
+	want := []string{
+		"100  a.txt",
+		"50  b.bin",
+		"50  b.link",
+		"# EXCLUDED: big.iso (larger than 1048576 bytes)",
+		`# EXCLUDED: build/ (ignored by "build/" in .gitignore)`,
+		"# SKIPPED: fifo (fifo)",
+	}
+
+	summary := NewDryRunSummary()
+	for i, r := range results {
+		summary.Add(r)
+		if i < len(want) {
+			if got := FormatDryRun(r, false); got != want[i] {
+				t.Errorf("FormatDryRun(%s) = %q, want %q", r.Path, got, want[i])
+			}
+		}
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(FormatDryRun(results[3], true)), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if data["excluded"] != "larger than 1048576 bytes" || data["type"] != "file" {
+		t.Errorf("JSON = %v", data)
+	}
+
+	if got, want := summary.Format(false), "# dry run: 3 files, 150 bytes (2 excluded, 1 skipped, 1 errors)"; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+	var report map[string]map[string]float64
+	if err := json.Unmarshal([]byte(summary.Format(true)), &report); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if report["dry_run"]["files"] != 3 || report["dry_run"]["bytes"] != 150 || report["dry_run"]["excluded"] != 2 {
+		t.Errorf("JSON summary = %v", report)
+	}
+}
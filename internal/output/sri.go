@@ -0,0 +1,126 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+	"github.com/Virace/fast-hasher/internal/scanner"
+)
+
+// SRIAlgorithms lists the hash algorithms allowed in Subresource Integrity metadata.
+var SRIAlgorithms = []string{"sha256", "sha384", "sha512"}
+
+// IsSRIAlgorithm reports whether algo may be used in an integrity attribute.
+func IsSRIAlgorithm(algo string) bool {
+	for _, a := range SRIAlgorithms {
+		if a == algo {
+			return true
+		}
+	}
+	return false
+}
+
+// integrity builds an SRI integrity string ("sha384-<base64> sha512-<base64>")
+// from a result. Hashes may be in any encoding accepted by hasher.Decode.
+func integrity(result *scanner.Result, algorithms []string) (string, error) {
+	parts := make([]string, 0, len(algorithms))
+	for _, algo := range algorithms {
+		h, ok := hasher.Get(algo)
+		if !ok || !IsSRIAlgorithm(algo) {
+			return "", fmt.Errorf("algorithm %s is not supported by SRI", algo)
+		}
+		sum, err := hasher.Decode(result.Hashes[algo], h.OutputSize())
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, algo+"-"+base64.StdEncoding.EncodeToString(sum))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// SRIFormatter formats results as Subresource Integrity strings, one file per line:
+// "sha384-<base64> [sha512-<base64>...]  path"
+type SRIFormatter struct {
+	// Algorithms is the list of algorithm names in order (sha256, sha384, sha512).
+	Algorithms []string
+}
+
+// NewSRIFormatter creates a new SRI formatter.
+func NewSRIFormatter(algorithms []string) *SRIFormatter {
+	return &SRIFormatter{Algorithms: algorithms}
+}
+
+// Format formats a successful result.
+func (f *SRIFormatter) Format(result *scanner.Result) string {
+	if line, ok := formatUnhashed(result); ok {
+		return line
+	}
+	value, err := integrity(result, f.Algorithms)
+	if err != nil {
+		return fmt.Sprintf("# ERROR: %s: %s", result.Path, err)
+	}
+	return fmt.Sprintf("%s  %s", value, result.Path)
+}
+
+// FormatError formats an error result.
+func (f *SRIFormatter) FormatError(result *scanner.Result) string {
+	return fmt.Sprintf("# ERROR: %s: %s", result.Path, result.Error)
+}
+
+// SRIMapFormatter collects results into a single JSON object mapping each
+// path to its integrity string, suitable for injecting into webpack or vite
+// manifests. Format returns an empty string; the object is produced by Finish.
+type SRIMapFormatter struct {
+	// Algorithms is the list of algorithm names in order (sha256, sha384, sha512).
+	Algorithms []string
+	// Base, if set, makes map keys relative to this directory.
+	// Keys always use forward slashes.
+	Base string
+
+	entries map[string]string
+}
+
+// NewSRIMapFormatter creates a new SRI map formatter.
+func NewSRIMapFormatter(algorithms []string, base string) *SRIMapFormatter {
+	return &SRIMapFormatter{
+		Algorithms: algorithms,
+		Base:       base,
+		entries:    make(map[string]string),
+	}
+}
+
+// Format records a successful result and returns an empty string.
+// Symlinks and skipped files have no integrity and are left out.
+func (f *SRIMapFormatter) Format(result *scanner.Result) string {
+	if _, ok := formatUnhashed(result); ok {
+		return ""
+	}
+	value, err := integrity(result, f.Algorithms)
+	if err != nil {
+		return fmt.Sprintf("# ERROR: %s: %s", result.Path, err)
+	}
+
+	key := result.Path
+	if f.Base != "" {
+		if rel, err := filepath.Rel(f.Base, result.Path); err == nil {
+			key = rel
+		}
+	}
+	f.entries[filepath.ToSlash(key)] = value
+	return ""
+}
+
+// FormatError formats an error result.
+func (f *SRIMapFormatter) FormatError(result *scanner.Result) string {
+	return fmt.Sprintf("# ERROR: %s: %s", result.Path, result.Error)
+}
+
+// Finish returns the JSON object of all recorded results with sorted keys.
+func (f *SRIMapFormatter) Finish() string {
+	b, _ := json.MarshalIndent(f.entries, "", "  ")
+	return string(b)
+}
@@ -13,6 +13,10 @@ type TextFormatter struct {
 	// Algorithms is the list of algorithm names in order.
 	// If multiple algorithms, each hash is output on a separate line as "algo:hash  path"
 	Algorithms []string
+	// Labeled prefixes hashes with the algorithm name even for a single
+	// algorithm, e.g. for keyed (HMAC) output that must not be mistaken
+	// for a plain digest.
+	Labeled bool
 }
 
 // NewTextFormatter creates a new text formatter.
@@ -22,9 +26,23 @@ func NewTextFormatter(algorithms []string) *TextFormatter {
 
 // Format formats a successful result.
 // Single algorithm: "hash  path"
-// Multiple algorithms: "algo:hash  path" (one line per algorithm)
+// Multiple algorithms or Labeled: "algo:hash  path" (one line per algorithm)
+// Content-defined chunks follow as comment lines, see formatCDCText.
+// Recorded symlinks and skipped special files are written as comments,
+// which checkers skip, see formatUnhashed.
 func (f *TextFormatter) Format(result *scanner.Result) string {
-	if len(f.Algorithms) == 1 {
+	if line, ok := formatUnhashed(result); ok {
+		return line
+	}
+	out := f.formatHashes(result)
+	if result.CDCChunks != nil {
+		out += formatCDCText(result, f.Algorithms)
+	}
+	return out
+}
+
+func (f *TextFormatter) formatHashes(result *scanner.Result) string {
+	if len(f.Algorithms) == 1 && !f.Labeled {
 		algo := f.Algorithms[0]
 		hash := result.Hashes[algo]
 		return fmt.Sprintf("%s  %s", hash, result.Path)
@@ -43,6 +61,23 @@ func (f *TextFormatter) Format(result *scanner.Result) string {
 	return strings.Join(lines, "\n")
 }
 
+// formatUnhashed formats a result without hashes as a comment line:
+// "# SYMLINK: path -> target", "# SKIPPED: path (type)" or
+// "# EXCLUDED: path (reason)", with a trailing slash for directories.
+func formatUnhashed(result *scanner.Result) (string, bool) {
+	switch {
+	case result.Excluded != "" && result.Type == scanner.TypeDir:
+		return fmt.Sprintf("# EXCLUDED: %s/ (%s)", result.Path, result.Excluded), true
+	case result.Excluded != "":
+		return fmt.Sprintf("# EXCLUDED: %s (%s)", result.Path, result.Excluded), true
+	case result.Type == scanner.TypeSymlink:
+		return fmt.Sprintf("# SYMLINK: %s -> %s", result.Path, result.Target), true
+	case result.Skipped:
+		return fmt.Sprintf("# SKIPPED: %s (%s)", result.Path, result.Type), true
+	}
+	return "", false
+}
+
 // FormatError formats an error result.
 func (f *TextFormatter) FormatError(result *scanner.Result) string {
 	return fmt.Sprintf("# ERROR: %s: %s", result.Path, result.Error)
@@ -0,0 +1,121 @@
+// Package ratelimit throttles the bytes and files read by all scanner
+// workers with token buckets, so that scans can run alongside live traffic.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// maxSleep bounds a single wait, so that waiters notice limit changes.
+const maxSleep = 100 * time.Millisecond
+
+// Limiter caps the rate of bytes read and files opened. It is shared by all
+// workers and its limits can be changed while in use. A nil *Limiter does
+// not limit anything.
+type Limiter struct {
+	bytes bucket
+	files bucket
+}
+
+// New creates a limiter allowing bytesPerSec bytes and filesPerSec files per
+// second. A limit of zero means unlimited.
+func New(bytesPerSec, filesPerSec float64) *Limiter {
+	l := &Limiter{}
+	l.SetLimits(bytesPerSec, filesPerSec)
+	return l
+}
+
+// SetLimits changes the limits; zero means unlimited. Callers blocked in a
+// wait pick up the new limits within a fraction of a second.
+func (l *Limiter) SetLimits(bytesPerSec, filesPerSec float64) {
+	l.SetBytes(bytesPerSec)
+	l.SetFiles(filesPerSec)
+}
+
+// SetBytes changes the byte rate limit; zero means unlimited.
+func (l *Limiter) SetBytes(perSec float64) { l.bytes.setRate(perSec) }
+
+// SetFiles changes the file rate limit; zero means unlimited.
+func (l *Limiter) SetFiles(perSec float64) { l.files.setRate(perSec) }
+
+// Limits returns the current limits; zero means unlimited.
+func (l *Limiter) Limits() (bytesPerSec, filesPerSec float64) {
+	if l == nil {
+		return 0, 0
+	}
+	return l.bytes.getRate(), l.files.getRate()
+}
+
+// WaitBytes accounts for n bytes read, blocking while over the byte rate.
+func (l *Limiter) WaitBytes(n int) {
+	if l != nil && n > 0 {
+		l.bytes.wait(float64(n))
+	}
+}
+
+// WaitFile blocks until another file may be opened.
+func (l *Limiter) WaitFile() {
+	if l != nil {
+		l.files.wait(1)
+	}
+}
+
+// bucket is a token bucket holding up to one second of tokens. Callers take
+// their tokens up front and may drive the balance negative, then wait until
+// it is repaid, so requests larger than the bucket still work.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second, 0 = unlimited
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) setRate(rate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	unlimited := b.rate == 0
+	b.rate = max(rate, 0)
+	// Start full when a limit is set; keep any debt when it changes
+	if unlimited || b.tokens > b.burst() {
+		b.tokens = b.burst()
+	}
+}
+
+func (b *bucket) getRate() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
+
+// burst returns the bucket capacity: one second of tokens, but at least one.
+func (b *bucket) burst() float64 {
+	return max(b.rate, 1)
+}
+
+// refill adds the tokens earned since the last refill.
+func (b *bucket) refill(now time.Time) {
+	if !b.last.IsZero() && b.rate > 0 {
+		b.tokens = min(b.burst(), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+func (b *bucket) wait(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate == 0 {
+		return
+	}
+	b.refill(time.Now())
+	b.tokens -= n
+
+	for b.rate > 0 && b.tokens < 0 {
+		d := time.Duration(-b.tokens / b.rate * float64(time.Second))
+		b.mu.Unlock()
+		time.Sleep(min(d, maxSleep))
+		b.mu.Lock()
+		b.refill(time.Now())
+	}
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_Bytes(t *testing.T) {
+	l := New(10000, 0)
+
+	// The first second of tokens is available immediately
+	start := time.Now()
+	l.WaitBytes(10000)
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("burst took %v, want immediate", d)
+	}
+
+	// Beyond that, reads are paced at the rate
+	start = time.Now()
+	l.WaitBytes(2000)
+	if d := time.Since(start); d < 150*time.Millisecond || d > time.Second {
+		t.Errorf("2000 bytes at 10000/s took %v, want about 200ms", d)
+	}
+}
+
+func TestLimiter_Files(t *testing.T) {
+	l := New(0, 20)
+	start := time.Now()
+	for range 25 {
+		l.WaitFile()
+	}
+	if d := time.Since(start); d < 200*time.Millisecond || d > 2*time.Second {
+		t.Errorf("25 files at 20/s took %v, want about 250ms", d)
+	}
+}
+
+func TestLimiter_SetLimits(t *testing.T) {
+	l := New(1000, 0)
+	l.WaitBytes(1000)
+
+	done := make(chan struct{})
+	go func() {
+		l.WaitBytes(1000000) // 1000 seconds at the initial rate
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	l.SetLimits(0, 5)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter not released after lifting the byte limit")
+	}
+	if bytes, files := l.Limits(); bytes != 0 || files != 5 {
+		t.Errorf("Limits() = %v, %v, want 0, 5", bytes, files)
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var l *Limiter
+	l.WaitBytes(1 << 30)
+	l.WaitFile()
+	if bytes, files := l.Limits(); bytes != 0 || files != 0 {
+		t.Errorf("Limits() = %v, %v, want 0, 0", bytes, files)
+	}
+}
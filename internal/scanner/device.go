@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+// DeviceLimits limits how many files on the same storage device are hashed
+// at a time, in addition to the overall Workers limit. A limit of zero
+// leaves that kind of device bounded only by Workers.
+type DeviceLimits struct {
+	Rotational    int // Workers per spinning disk
+	NonRotational int // Workers per SSD or other non-rotational device
+}
+
+// DefaultDeviceLimits hashes one file at a time per spinning disk, where
+// concurrent reads turn into seeks, and leaves other devices unlimited.
+func DefaultDeviceLimits() *DeviceLimits {
+	return &DeviceLimits{Rotational: 1}
+}
+
+// device is a storage device with its share of the workers.
+type device struct {
+	slots      hasher.Workers // nil if unlimited
+	rotational bool
+}
+
+// checkRotational reports whether a block device is a spinning disk, and
+// whether that is known. It is a variable so that tests can fake disks.
+var checkRotational = isRotational
+
+// deviceOf returns the device holding a file, or nil if per-device limits
+// are disabled or its device cannot be determined. Devices are shared by all
+// scans of the scanner, so roots on the same disk share its limit.
+func (s *Scanner) deviceOf(info fs.FileInfo) *device {
+	if s.Devices == nil {
+		return nil
+	}
+	id, ok := deviceID(info)
+	if !ok {
+		return nil
+	}
+
+	s.devicesMu.Lock()
+	defer s.devicesMu.Unlock()
+	if d, ok := s.devices[id]; ok {
+		return d
+	}
+
+	d := &device{}
+	limit := s.Devices.NonRotational
+	if rot, ok := checkRotational(id); ok && rot {
+		d.rotational = true
+		limit = s.Devices.Rotational
+	}
+	if limit > 0 {
+		d.slots = hasher.NewWorkers(limit)
+	}
+	if s.devices == nil {
+		s.devices = make(map[uint64]*device)
+	}
+	s.devices[id] = d
+	return d
+}
+
+// deviceQueue is the files of a scan on one device, in scan order.
+type deviceQueue struct {
+	device *device // nil for files without a known device
+	paths  []string
+}
+
+// groupByDevice splits paths into one queue per device, each sorted in the
+// scanner's read order.
+func (s *Scanner) groupByDevice(paths []string) []*deviceQueue {
+	if s.Devices == nil && s.Order == WalkOrder {
+		return []*deviceQueue{{paths: paths}}
+	}
+
+	var queues []*deviceQueue
+	byDevice := make(map[*device]*deviceQueue)
+	keys := make(map[*deviceQueue][]*orderKey)
+	for _, path := range paths {
+		var d *device
+		var key *orderKey
+		if info, err := os.Stat(path); err == nil {
+			d = s.deviceOf(info)
+			if s.Order != WalkOrder {
+				k := readOrderKey(path, info, s.Order)
+				key = &k
+			}
+		}
+
+		q, ok := byDevice[d]
+		if !ok {
+			q = &deviceQueue{device: d}
+			byDevice[d] = q
+			queues = append(queues, q)
+		}
+		q.paths = append(q.paths, path)
+		keys[q] = append(keys[q], key)
+	}
+
+	if s.Order != WalkOrder {
+		for _, q := range queues {
+			q.sortByKey(keys[q])
+		}
+	}
+	return queues
+}
+
+// run calls fn for every path on its own goroutine while holding one of the
+// workers and a slot of the path's device. Each device is fed from its own
+// queue, so a busy disk does not hold up files on other devices. Files on
+// spinning disks get no workers to hash segments with, so that each is read
+// sequentially.
+func (s *Scanner) run(paths []string, workers hasher.Workers, fn func(path string, workers hasher.Workers)) {
+	var wg sync.WaitGroup
+	for _, q := range s.groupByDevice(paths) {
+		segmentWorkers := workers
+		var slots hasher.Workers
+		if q.device != nil {
+			slots = q.device.slots
+			if q.device.rotational {
+				segmentWorkers = nil
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, path := range q.paths {
+				// Wait for the device first, so that files queued for a busy
+				// device do not hold workers that others could use
+				if slots != nil {
+					slots.Acquire()
+				}
+				workers.Acquire()
+				wg.Add(1)
+
+				go func() {
+					defer func() {
+						workers.Release()
+						if slots != nil {
+							slots.Release()
+						}
+						wg.Done()
+					}()
+					fn(path, segmentWorkers)
+				}()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// deviceID returns the ID of the device holding a file (st_dev).
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
+
+// isRotational reads /sys/block/<disk>/queue/rotational for a device ID.
+// Partitions have no queue of their own and use their disk's. Devices that
+// are not block devices, such as network filesystems, tmpfs and btrfs
+// subvolumes, are unknown.
+func isRotational(dev uint64) (bool, bool) {
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	if major == 0 {
+		return false, false
+	}
+
+	// The kernel resolves ".." after following the symlink, so the second
+	// path reaches the disk of a partition
+	base := fmt.Sprintf("/sys/dev/block/%d:%d", major, minor)
+	for _, path := range []string{base + "/queue/rotational", base + "/../queue/rotational"} {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data)) == "1", true
+		}
+	}
+	return false, false
+}
+
+// inode returns the inode number of a file.
+func inode(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Ino), true
+}
+
+// nlink returns the number of hardlinks to a file.
+func nlink(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package scanner
+
+import "io/fs"
+
+// deviceID is only implemented on Linux; elsewhere per-device limits do not
+// apply.
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func isRotational(dev uint64) (bool, bool) {
+	return false, false
+}
+
+func inode(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func nlink(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,699 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Expr is a compiled filter expression such as
+//
+//	(ext in [.jpg, .png] and size > 1MB) or path ~ "^raw/"
+//
+// Comparisons are combined with and, or and not (also &&, || and !), and
+// grouped with parentheses. Fields:
+//
+//	name   base name                   path   slash-separated path relative to the scan root
+//	ext    lowercase extension (.jpg)  type   file, symlink, fifo, socket, chardev or blockdev
+//	size   bytes (1MB = 1048576)       age    time since modification (30m, 12h, 7d, 2w)
+//	mtime  modification time           ctime  status change time (Linux only)
+//	uid    owner user ID (Linux only)  gid    owner group ID (Linux only)
+//	perm   octal permission bits       hidden dot file or Windows hidden attribute
+//
+// Operators are == (or =), !=, <, <=, >, >=, ~ and !~ (regexp, unanchored),
+// glob (with ** and {a,b}) and in [list]. Times are dates, RFC 3339 times or
+// ages before now, so mtime > 7d means modified in the last 7 days. Strings
+// are quoted with " or ', or written bare if they have no spaces or special
+// characters. Comparisons on values that are unknown, such as the owner on
+// Windows, are false.
+type Expr struct {
+	src  string
+	eval func(*fileFacts) bool
+}
+
+// fileFacts is what an expression is evaluated on.
+type fileFacts struct {
+	path string // as given
+	rel  string // relative to the scan root
+	size int64
+	info fs.FileInfo // nil if only the path and size are known
+}
+
+// ExprError is a syntax or type error in a filter expression.
+type ExprError struct {
+	Expr string
+	Pos  int // byte offset in Expr
+	Msg  string
+}
+
+// Column returns the 1-based column of the error, in characters.
+func (e *ExprError) Column() int {
+	return utf8.RuneCountInString(e.Expr[:e.Pos]) + 1
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column(), e.Msg)
+}
+
+// Context returns the expression with a caret under the error position.
+func (e *ExprError) Context() string {
+	return e.Expr + "\n" + strings.Repeat(" ", e.Column()-1) + "^"
+}
+
+// ParseExpr compiles a filter expression. Relative times are resolved
+// against now. Errors are *ExprError.
+func ParseExpr(src string, now time.Time) (*Expr, error) {
+	p := &exprParser{src: src, now: now}
+	p.next()
+	eval, err := p.parseOr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.unexpected(`"and", "or" or end of expression`)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, eval: eval}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match reports whether a file matches the expression. Only fields that
+// depend on the path and size are known if info is nil.
+func (e *Expr) Match(path string, size int64, info fs.FileInfo) bool {
+	return e.match(path, path, size, info)
+}
+
+func (e *Expr) match(path, rel string, size int64, info fs.FileInfo) bool {
+	return e.eval(&fileFacts{path: path, rel: filepath.ToSlash(rel), size: size, info: info})
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokInvalid
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	text string // word, unquoted string or operator
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	case tokInvalid:
+		return "character " + strconv.Quote(t.text)
+	}
+	return strconv.Quote(t.text)
+}
+
+// isKeyword reports whether the token is the given keyword, in any case.
+func (t token) isKeyword(kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+// Operators, longest first so that "<=" is not read as "<"
+var exprOps = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "=", "<", ">", "~", "!"}
+
+// isWordRune reports whether r can be part of a bare word.
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()[],"'=!<>~&|`, r)
+}
+
+// Parser
+
+type exprParser struct {
+	src string
+	pos int
+	tok token
+	err *ExprError // from the lexer
+	now time.Time
+}
+
+func (p *exprParser) errorf(pos int, format string, args ...any) *ExprError {
+	return &ExprError{Expr: p.src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next reads the next token into p.tok.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) {
+		r, n := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		p.pos += n
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	switch c := p.src[p.pos]; c {
+	case '(', ')', '[', ']', ',':
+		p.pos++
+		kind := map[byte]tokenKind{'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket, ',': tokComma}[c]
+		p.tok = token{kind: kind, pos: start, text: string(c)}
+		return
+	case '"', '\'':
+		p.tok = p.lexString(c)
+		return
+	}
+	for _, op := range exprOps {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			p.tok = token{kind: tokOp, pos: start, text: op}
+			return
+		}
+	}
+
+	for p.pos < len(p.src) {
+		r, n := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !isWordRune(r) {
+			break
+		}
+		p.pos += n
+	}
+	if p.pos == start {
+		// A lone & or |
+		_, n := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += n
+		p.tok = token{kind: tokInvalid, pos: start, text: p.src[start:p.pos]}
+		return
+	}
+	p.tok = token{kind: tokWord, pos: start, text: p.src[start:p.pos]}
+}
+
+// lexString reads a quoted string. A backslash escapes the quote and itself;
+// other backslashes are kept, so that regexps need no double escaping.
+func (p *exprParser) lexString(quote byte) token {
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return token{kind: tokString, pos: start, text: b.String()}
+		case c == '\\' && p.pos+1 < len(p.src) && (p.src[p.pos+1] == quote || p.src[p.pos+1] == '\\'):
+			b.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	if p.err == nil {
+		p.err = p.errorf(start, "unterminated string")
+	}
+	return token{kind: tokInvalid, pos: start, text: string(quote)}
+}
+
+// unexpected returns the lexer error, if any, or an error for an unexpected
+// token.
+func (p *exprParser) unexpected(want string) *ExprError {
+	if p.err != nil {
+		return p.err
+	}
+	return p.errorf(p.tok.pos, "expected %s, found %s", want, p.tok)
+}
+
+// parseOr parses: and { ("or" | "||") and }
+func (p *exprParser) parseOr() (func(*fileFacts) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.isKeyword("or") || (p.tok.kind == tokOp && p.tok.text == "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *fileFacts) bool { return l(f) || right(f) }
+	}
+	return left, nil
+}
+
+// parseAnd parses: unary { ("and" | "&&") unary }
+func (p *exprParser) parseAnd() (func(*fileFacts) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.isKeyword("and") || (p.tok.kind == tokOp && p.tok.text == "&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *fileFacts) bool { return l(f) && right(f) }
+	}
+	return left, nil
+}
+
+// parseUnary parses: ("not" | "!") unary | "(" or ")" | comparison
+func (p *exprParser) parseUnary() (func(*fileFacts) bool, error) {
+	switch {
+	case p.tok.isKeyword("not") || (p.tok.kind == tokOp && p.tok.text == "!"):
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(f *fileFacts) bool { return !x(f) }, nil
+
+	case p.tok.kind == tokLParen:
+		open := p.tok.pos
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			if p.tok.kind == tokEOF && p.err == nil {
+				return nil, p.errorf(open, "unclosed parenthesis")
+			}
+			return nil, p.unexpected(`")"`)
+		}
+		p.next()
+		return x, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses: field [op value | "in" list | "glob" value]
+func (p *exprParser) parseComparison() (func(*fileFacts) bool, error) {
+	if p.tok.kind != tokWord {
+		return nil, p.unexpected("field name")
+	}
+	name := strings.ToLower(p.tok.text)
+	field, ok := exprFields[name]
+	if !ok {
+		return nil, p.errorf(p.tok.pos, "unknown field %q (known: %s)", p.tok.text, strings.Join(exprFieldNames(), ", "))
+	}
+	p.next()
+
+	if field.kind == kindBool {
+		return func(f *fileFacts) bool {
+			v, ok := field.num(f)
+			return ok && v != 0
+		}, nil
+	}
+
+	op := p.tok
+	switch {
+	case op.kind == tokOp && slices.Contains([]string{"==", "=", "!=", "<", "<=", ">", ">=", "~", "!~"}, op.text):
+	case op.isKeyword("in") || op.isKeyword("glob"):
+		op.text = strings.ToLower(op.text)
+	default:
+		return nil, p.unexpected("operator after " + name)
+	}
+	if !field.kind.allows(op.text) {
+		return nil, p.errorf(op.pos, "operator %s cannot be used with %s", op.text, name)
+	}
+	p.next()
+
+	if op.text == "in" {
+		return p.parseIn(name, field)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case "~", "!~":
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, p.errorf(value.pos, "invalid regexp: %v", unwrapRegexpError(err))
+		}
+		negate := op.text == "!~"
+		return func(f *fileFacts) bool {
+			v, ok := field.str(f)
+			return ok && re.MatchString(v) != negate
+		}, nil
+
+	case "glob":
+		patterns := expandBraces(value.text)
+		for _, pattern := range patterns {
+			if _, err := pathpkg.Match(pattern, ""); err != nil {
+				return nil, p.errorf(value.pos, "invalid glob pattern %q", value.text)
+			}
+		}
+		return func(f *fileFacts) bool {
+			v, ok := field.str(f)
+			return ok && slices.ContainsFunc(patterns, func(pattern string) bool { return matchPath(pattern, v) })
+		}, nil
+	}
+
+	if field.kind == kindString {
+		want := field.normalize(value.text)
+		equal := op.text != "!="
+		return func(f *fileFacts) bool {
+			v, ok := field.str(f)
+			return ok && (v == want) == equal
+		}, nil
+	}
+
+	want, err := p.number(field.kind, value)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *fileFacts) bool {
+		v, ok := field.num(f)
+		return ok && compare(v, op.text, want)
+	}, nil
+}
+
+// parseIn parses the list after "in".
+func (p *exprParser) parseIn(name string, field *exprField) (func(*fileFacts) bool, error) {
+	if p.tok.kind != tokLBracket {
+		return nil, p.unexpected(`"[" after in`)
+	}
+	p.next()
+
+	var strs []string
+	var nums []int64
+	for p.tok.kind != tokRBracket {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if field.kind == kindString {
+			strs = append(strs, field.normalize(value.text))
+		} else {
+			n, err := p.number(field.kind, value)
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, n)
+		}
+
+		if p.tok.kind == tokComma {
+			p.next()
+		} else if p.tok.kind != tokRBracket {
+			return nil, p.unexpected(`"," or "]"`)
+		}
+	}
+	p.next()
+
+	if field.kind == kindString {
+		return func(f *fileFacts) bool {
+			v, ok := field.str(f)
+			return ok && slices.Contains(strs, v)
+		}, nil
+	}
+	return func(f *fileFacts) bool {
+		v, ok := field.num(f)
+		return ok && slices.Contains(nums, v)
+	}, nil
+}
+
+// parseValue parses a bare word or a quoted string.
+func (p *exprParser) parseValue() (token, error) {
+	if p.tok.kind != tokWord && p.tok.kind != tokString {
+		return token{}, p.unexpected("value")
+	}
+	value := p.tok
+	p.next()
+	return value, nil
+}
+
+// number converts a value for a numeric field.
+func (p *exprParser) number(kind valueKind, value token) (int64, error) {
+	switch kind {
+	case kindSize:
+		n, err := parseSizeLiteral(value.text)
+		if err != nil {
+			return 0, p.errorf(value.pos, "invalid size %q (use bytes or a unit such as 100KB, 1MB, 2GB)", value.text)
+		}
+		return n, nil
+	case kindTime:
+		t, err := ParseTime(value.text, p.now)
+		if err != nil {
+			return 0, p.errorf(value.pos, "invalid time %q (use a date such as 2024-01-31, an RFC 3339 time or an age such as 7d)", value.text)
+		}
+		if t.Year() < 1678 || t.Year() > 2261 {
+			// Out of the range of nanosecond timestamps
+			return 0, p.errorf(value.pos, "time %q out of range", value.text)
+		}
+		return t.UnixNano(), nil
+	case kindDuration:
+		d, err := parseAge(value.text)
+		if err != nil {
+			return 0, p.errorf(value.pos, "invalid age %q (use a duration such as 30m, 12h, 7d or 2w)", value.text)
+		}
+		return int64(d), nil
+	case kindPerm:
+		bits, err := strconv.ParseUint(value.text, 8, 32)
+		if err != nil || bits > 0o7777 {
+			return 0, p.errorf(value.pos, "invalid permission %q (use octal bits such as 644)", value.text)
+		}
+		return int64(permBits(fs.FileMode(bits))), nil
+	default:
+		n, err := strconv.ParseInt(value.text, 10, 64)
+		if err != nil {
+			return 0, p.errorf(value.pos, "invalid number %q", value.text)
+		}
+		return n, nil
+	}
+}
+
+// compare applies a comparison operator.
+func compare(v int64, op string, want int64) bool {
+	switch op {
+	case "==", "=":
+		return v == want
+	case "!=":
+		return v != want
+	case "<":
+		return v < want
+	case "<=":
+		return v <= want
+	case ">":
+		return v > want
+	default:
+		return v >= want
+	}
+}
+
+// unwrapRegexpError drops the "error parsing regexp: " prefix, which the
+// position already implies.
+func unwrapRegexpError(err error) string {
+	return strings.TrimPrefix(err.Error(), "error parsing regexp: ")
+}
+
+// Fields
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindSize
+	kindTime
+	kindDuration
+	kindNumber
+	kindPerm
+	kindBool
+)
+
+// allows reports whether an operator can be used with a kind of value.
+func (k valueKind) allows(op string) bool {
+	switch op {
+	case "~", "!~", "glob":
+		return k == kindString
+	case "<", "<=", ">", ">=":
+		return k != kindString && k != kindPerm
+	}
+	return true
+}
+
+// exprField is a field that expressions can test. String fields have str,
+// the others num.
+type exprField struct {
+	kind      valueKind
+	str       func(*fileFacts) (string, bool)
+	num       func(*fileFacts) (int64, bool)
+	normalize func(string) string // applied to string values compared with ==, != and in
+}
+
+func exprFieldNames() []string {
+	names := make([]string, 0, len(exprFields))
+	for name := range exprFields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+var exprFields = map[string]*exprField{
+	"name": {kind: kindString, str: func(f *fileFacts) (string, bool) {
+		return pathpkg.Base(f.rel), true
+	}},
+	"path": {kind: kindString, str: func(f *fileFacts) (string, bool) {
+		return f.rel, true
+	}},
+	"ext": {
+		kind: kindString,
+		str: func(f *fileFacts) (string, bool) {
+			return strings.ToLower(pathpkg.Ext(f.rel)), true
+		},
+		normalize: func(s string) string { return strings.ToLower(normalizeExt(s)) },
+	},
+	"type": {kind: kindString, str: func(f *fileFacts) (string, bool) {
+		if f.info == nil {
+			return "", false
+		}
+		return string(fileType(f.info.Mode())), true
+	}},
+	"size": {kind: kindSize, num: func(f *fileFacts) (int64, bool) {
+		return f.size, true
+	}},
+	"mtime": {kind: kindTime, num: func(f *fileFacts) (int64, bool) {
+		if f.info == nil {
+			return 0, false
+		}
+		return f.info.ModTime().UnixNano(), true
+	}},
+	"ctime": {kind: kindTime, num: func(f *fileFacts) (int64, bool) {
+		if f.info == nil {
+			return 0, false
+		}
+		t, ok := changeTime(f.info)
+		return t.UnixNano(), ok
+	}},
+	"age": {kind: kindDuration, num: func(f *fileFacts) (int64, bool) {
+		if f.info == nil {
+			return 0, false
+		}
+		return int64(time.Since(f.info.ModTime())), true
+	}},
+	"uid": {kind: kindNumber, num: func(f *fileFacts) (int64, bool) {
+		if f.info == nil {
+			return 0, false
+		}
+		uid, _, ok := owner(f.info)
+		return int64(uid), ok
+	}},
+	"gid": {kind: kindNumber, num: func(f *fileFacts) (int64, bool) {
+		if f.info == nil {
+			return 0, false
+		}
+		_, gid, ok := owner(f.info)
+		return int64(gid), ok
+	}},
+	"perm": {kind: kindPerm, num: func(f *fileFacts) (int64, bool) {
+		if f.info == nil {
+			return 0, false
+		}
+		return int64(f.info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)), true
+	}},
+	"hidden": {kind: kindBool, num: func(f *fileFacts) (int64, bool) {
+		if f.info == nil {
+			return 0, false
+		}
+		if isHidden(f.rel, f.info) {
+			return 1, true
+		}
+		return 0, true
+	}},
+}
+
+func init() {
+	for _, field := range exprFields {
+		if field.normalize == nil {
+			field.normalize = func(s string) string { return s }
+		}
+	}
+}
+
+// Values
+
+// parseSizeLiteral parses a size in bytes with an optional binary unit,
+// like the --max-size flag.
+func parseSizeLiteral(s string) (int64, error) {
+	upper := strings.ToUpper(s)
+	multiplier := int64(1)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(upper, unit+"B") || strings.HasSuffix(upper, unit) {
+			multiplier = 1 << (10 * (i + 1))
+			upper = strings.TrimSuffix(strings.TrimSuffix(upper, "B"), unit)
+			break
+		}
+	}
+	if multiplier == 1 {
+		upper = strings.TrimSuffix(upper, "B")
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// parseAge parses a duration, also accepting days (7d) and weeks (2w).
+func parseAge(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if n, err := strconv.ParseUint(s[:max(len(s)-1, 0)], 10, 16); unit > 0 && err == nil {
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
+
+// ParseTime parses an absolute time, a date or an RFC 3339 time in local
+// time unless it has a zone, or an age before now such as 7d, 2w or 1h30m.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if d, err := parseAge(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s (use a date such as 2024-01-31, an RFC 3339 time or an age such as 7d)", s)
+}
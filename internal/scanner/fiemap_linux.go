@@ -0,0 +1,42 @@
+//go:build linux && (amd64 || arm64)
+
+package scanner
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// fiemapSupported reports whether physicalOffset is implemented on this
+// platform.
+const fiemapSupported = true
+
+// FIEMAP ioctl from <linux/fiemap.h>: struct fiemap is 32 bytes followed by
+// fm_extent_count struct fiemap_extent of 56 bytes each.
+const (
+	fsIocFiemap      = 0xC020660B // _IOWR('f', 11, struct fiemap)
+	fiemapHeaderSize = 32
+	fiemapExtentSize = 56
+)
+
+// physicalOffset returns the disk offset of the first extent of a file.
+// Empty files and filesystems without FIEMAP support have none.
+func physicalOffset(path string) (uint64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	// Request a single extent of the whole file, without syncing it
+	var buf [fiemapHeaderSize + fiemapExtentSize]byte
+	binary.NativeEndian.PutUint64(buf[8:], ^uint64(0)) // fm_length
+	binary.NativeEndian.PutUint32(buf[24:], 1)         // fm_extent_count
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocFiemap, uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 || binary.NativeEndian.Uint32(buf[20:]) == 0 { // fm_mapped_extents
+		return 0, false
+	}
+	return binary.NativeEndian.Uint64(buf[fiemapHeaderSize+8:]), true // fe_physical
+}
@@ -0,0 +1,12 @@
+//go:build !linux || !(amd64 || arm64)
+
+package scanner
+
+// fiemapSupported reports whether physicalOffset is implemented on this
+// platform.
+const fiemapSupported = false
+
+// physicalOffset is only implemented on Linux.
+func physicalOffset(path string) (uint64, bool) {
+	return 0, false
+}
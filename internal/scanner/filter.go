@@ -2,8 +2,15 @@
 package scanner
 
 import (
+	"fmt"
+	"io/fs"
+	pathpkg "path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // FilterOptions defines criteria for filtering files during scanning.
@@ -12,8 +19,32 @@ type FilterOptions struct {
 	MinSize      int64    // Skip files smaller than this size
 	IncludeExts  []string // Only process files with these extensions (whitelist, takes priority)
 	ExcludeExts  []string // Skip files with these extensions (blacklist)
-	IncludeGlobs []string // Include patterns (glob)
-	ExcludeGlobs []string // Exclude patterns (glob)
+	IncludeGlobs []string // Include patterns (glob with "**" and {a,b})
+	ExcludeGlobs []string // Exclude patterns; directories they match are not walked
+	IgnoreCase   bool     // Match glob patterns case-insensitively
+
+	// Regular expressions, matched anywhere in the slash-separated path
+	// relative to the scan root or as given
+	IncludeRegexps []*regexp.Regexp // Only process files matching one of these
+	ExcludeRegexps []*regexp.Regexp // Skip files matching any of these
+
+	// Filters on file info, checked by MatchFile and during directory walks
+	ModifiedAfter  time.Time   // Skip files modified before this time (zero = no limit)
+	ModifiedBefore time.Time   // Skip files modified at or after this time
+	ChangedAfter   time.Time   // Skip files whose status changed before this time (Linux only)
+	ChangedBefore  time.Time   // Skip files whose status changed at or after this time (Linux only)
+	Owners         []uint32    // Only process files owned by one of these user IDs (Linux only)
+	Groups         []uint32    // Only process files owned by one of these group IDs (Linux only)
+	Perm           *PermFilter // Only process files whose permission bits match
+	SkipHidden     bool        // Skip hidden files (dot files, or with the hidden attribute on Windows)
+
+	// Expr, if set, must also match. See ParseExpr for the syntax.
+	Expr *Expr
+
+	// Ignore files in gitignore syntax, applied while walking directories.
+	// Ignored directories are not entered.
+	GitIgnore  bool   // Read .gitignore in every directory, scoped to it, and skip .git
+	IgnoreFile string // Project ignore file, relative to each scanned directory unless absolute
 }
 
 // Match returns true if the file matches the filter criteria.
@@ -21,26 +52,192 @@ type FilterOptions struct {
 // 1. Its size is within the min/max range
 // 2. It passes extension filters (include takes priority over exclude)
 // 3. It passes glob pattern filters (include takes priority over exclude)
+//
+// Glob patterns are matched against the path as given; during a directory
+// walk they are also matched relative to the scanned directory. Filters
+// that need more than the size are only checked by MatchFile.
 func (f *FilterOptions) Match(path string, size int64) bool {
+	return f.match(path, path, size, nil)
+}
+
+// MatchFile is Match that also checks the filters on file info: times,
+// owner, permissions and hidden files.
+func (f *FilterOptions) MatchFile(path string, info fs.FileInfo) bool {
+	return f.match(path, path, info.Size(), info)
+}
+
+// match is Match for a file at rel, a path relative to the scan root, also
+// checking the file info if given.
+func (f *FilterOptions) match(path, rel string, size int64, info fs.FileInfo) bool {
+	return f.reject(path, rel, size, info) == ""
+}
+
+// reject returns why a file does not match, or "" if it does.
+func (f *FilterOptions) reject(path, rel string, size int64, info fs.FileInfo) string {
 	// Size filter
 	if f.MaxSize > 0 && size > f.MaxSize {
-		return false
+		return fmt.Sprintf("larger than %d bytes", f.MaxSize)
 	}
 	if f.MinSize > 0 && size < f.MinSize {
-		return false
+		return fmt.Sprintf("smaller than %d bytes", f.MinSize)
 	}
 
 	// Extension filter
 	if !f.matchExtension(path) {
-		return false
+		if len(f.IncludeExts) > 0 {
+			return "extension not included"
+		}
+		return "extension excluded"
 	}
 
 	// Glob filter
-	if !f.matchGlob(path) {
-		return false
+	if len(f.IncludeGlobs) > 0 && !f.matchAny(f.IncludeGlobs, path, rel) {
+		return "no include pattern matches"
+	}
+	if f.matchAny(f.ExcludeGlobs, path, rel) {
+		return "matches an exclude pattern"
 	}
 
-	return true
+	// Regexp filter
+	if len(f.IncludeRegexps) > 0 && !matchRegexps(f.IncludeRegexps, path, rel) {
+		return "no include regexp matches"
+	}
+	if matchRegexps(f.ExcludeRegexps, path, rel) {
+		return "matches an exclude regexp"
+	}
+
+	if info != nil {
+		if reason := f.rejectInfo(path, info); reason != "" {
+			return reason
+		}
+	}
+
+	// Filter expression
+	if f.Expr != nil && !f.Expr.match(path, rel, size, info) {
+		return "filter expression is false"
+	}
+	return ""
+}
+
+// matchRegexps reports whether any of the regular expressions matches a
+// file's path relative to the scan root or as given.
+func matchRegexps(res []*regexp.Regexp, path, rel string) bool {
+	path, rel = filepath.ToSlash(path), filepath.ToSlash(rel)
+	for _, re := range res {
+		if re.MatchString(rel) || re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectInfo checks the filters on file info, returning why a file does not
+// match. Files whose owner or status change time is unknown do not match
+// filters on them.
+func (f *FilterOptions) rejectInfo(path string, info fs.FileInfo) string {
+	if !inRange(info.ModTime(), f.ModifiedAfter, f.ModifiedBefore) {
+		return "modification time out of range"
+	}
+	if !f.ChangedAfter.IsZero() || !f.ChangedBefore.IsZero() {
+		ctime, ok := changeTime(info)
+		if !ok || !inRange(ctime, f.ChangedAfter, f.ChangedBefore) {
+			return "status change time out of range"
+		}
+	}
+
+	if len(f.Owners) > 0 || len(f.Groups) > 0 {
+		uid, gid, ok := owner(info)
+		if !ok || (len(f.Owners) > 0 && !slices.Contains(f.Owners, uid)) {
+			return "owner not included"
+		}
+		if len(f.Groups) > 0 && !slices.Contains(f.Groups, gid) {
+			return "group not included"
+		}
+	}
+
+	if f.Perm != nil && !f.Perm.Match(info.Mode()) {
+		return "permissions do not match"
+	}
+
+	if f.SkipHidden && isHidden(path, info) {
+		return "hidden file"
+	}
+	return ""
+}
+
+// inRange reports whether t is in [after, before), where zero bounds are
+// open.
+func inRange(t, after, before time.Time) bool {
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || t.Before(before))
+}
+
+// isHidden reports whether a file is hidden: its name starts with a dot, or
+// it has the hidden attribute on Windows.
+func isHidden(path string, info fs.FileInfo) bool {
+	name := filepath.Base(path)
+	return (strings.HasPrefix(name, ".") && name != "." && name != "..") || hiddenAttribute(info)
+}
+
+// PermMatch is how PermFilter compares permission bits.
+type PermMatch int
+
+const (
+	PermExact PermMatch = iota // All permission bits equal Bits
+	PermAll                    // All of Bits are set
+	PermAny                    // At least one of Bits is set
+)
+
+// PermFilter matches files by permission bits, like find -perm.
+type PermFilter struct {
+	Bits fs.FileMode
+	Mode PermMatch
+}
+
+// ParsePermFilter parses an octal permission filter in find syntax: "644"
+// for exactly these bits, "-111" for all of them, "/111" for any of them.
+func ParsePermFilter(s string) (*PermFilter, error) {
+	p := &PermFilter{}
+	switch {
+	case strings.HasPrefix(s, "-"):
+		p.Mode, s = PermAll, s[1:]
+	case strings.HasPrefix(s, "/"):
+		p.Mode, s = PermAny, s[1:]
+	}
+	bits, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || bits > 0o7777 {
+		return nil, fmt.Errorf("invalid permission: %s (use octal bits such as 644, -111 or /022)", s)
+	}
+	p.Bits = permBits(fs.FileMode(bits))
+	return p, nil
+}
+
+// permBits converts the octal setuid, setgid and sticky bits to their
+// fs.FileMode flags.
+func permBits(m fs.FileMode) fs.FileMode {
+	mode := m & fs.ModePerm
+	if m&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// Match reports whether a file mode matches the filter.
+func (p *PermFilter) Match(mode fs.FileMode) bool {
+	mode &= fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+	switch p.Mode {
+	case PermAll:
+		return mode&p.Bits == p.Bits
+	case PermAny:
+		return mode&p.Bits != 0 || p.Bits == 0
+	default:
+		return mode == p.Bits
+	}
 }
 
 // matchExtension checks extension filters.
@@ -71,44 +268,46 @@ func (f *FilterOptions) matchExtension(path string) bool {
 	return true
 }
 
-// matchGlob checks glob pattern filters.
-func (f *FilterOptions) matchGlob(path string) bool {
+// excludesDir reports whether an exclude pattern matches a directory, so
+// that it is not walked at all.
+func (f *FilterOptions) excludesDir(path, rel string) bool {
+	return f.matchAny(f.ExcludeGlobs, path, rel)
+}
+
+// matchAny reports whether a file matches any of the patterns, after brace
+// expansion. Patterns containing a slash match the path relative to the scan
+// root or the path as given, with "**" matching any number of directories;
+// other patterns match the base name.
+func (f *FilterOptions) matchAny(patterns []string, path, rel string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+
 	// Normalize path separators for cross-platform matching
-	normalizedPath := filepath.ToSlash(path)
-	baseName := filepath.Base(path)
-
-	// If include patterns are set, file must match at least one
-	if len(f.IncludeGlobs) > 0 {
-		matched := false
-		for _, pattern := range f.IncludeGlobs {
-			// Try matching against full path and basename
-			if m, _ := filepath.Match(pattern, normalizedPath); m {
-				matched = true
-				break
-			}
-			if m, _ := filepath.Match(pattern, baseName); m {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return false
-		}
+	path, rel = filepath.ToSlash(path), filepath.ToSlash(rel)
+	if f.IgnoreCase {
+		path, rel = strings.ToLower(path), strings.ToLower(rel)
 	}
+	base := pathpkg.Base(rel)
 
-	// If exclude patterns are set, file must not match any
-	if len(f.ExcludeGlobs) > 0 {
-		for _, pattern := range f.ExcludeGlobs {
-			if m, _ := filepath.Match(pattern, normalizedPath); m {
-				return false
+	for _, pattern := range patterns {
+		if f.IgnoreCase {
+			pattern = strings.ToLower(pattern)
+		}
+		for _, p := range expandBraces(pattern) {
+			if !strings.Contains(p, "/") {
+				if m, _ := pathpkg.Match(p, base); m {
+					return true
+				}
+				continue
 			}
-			if m, _ := filepath.Match(pattern, baseName); m {
-				return false
+			p = strings.TrimPrefix(p, "./")
+			if matchPath(strings.TrimPrefix(p, "/"), rel) || matchPath(p, path) {
+				return true
 			}
 		}
 	}
-
-	return true
+	return false
 }
 
 // normalizeExt ensures the extension starts with a dot.
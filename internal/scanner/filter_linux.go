@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// owner returns the user and group IDs of a file.
+func owner(info fs.FileInfo) (uid, gid uint32, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return stat.Uid, stat.Gid, true
+}
+
+// changeTime returns the time a file's status last changed (st_ctime).
+func changeTime(info fs.FileInfo) (time.Time, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Ctim.Unix()), true
+}
+
+// hiddenAttribute reports whether a file has a hidden attribute; Linux has
+// none besides dot files.
+func hiddenAttribute(info fs.FileInfo) bool {
+	return false
+}
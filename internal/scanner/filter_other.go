@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package scanner
+
+import (
+	"io/fs"
+	"time"
+)
+
+// owner and changeTime are only implemented on Linux.
+func owner(info fs.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
+
+func changeTime(info fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func hiddenAttribute(info fs.FileInfo) bool {
+	return false
+}
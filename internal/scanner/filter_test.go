@@ -1,7 +1,13 @@
 package scanner
 
 import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFilterOptions_Match_Size(t *testing.T) {
@@ -174,3 +180,326 @@ func TestFilterOptions_Match_Glob(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/x/y/c", true},
+		{"a/**", "a/x/y", true},
+		{"a/**", "b/x", false},
+		{"a/**/c", "a/x/d", false},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	root, _ := parseIgnore(strings.NewReader(strings.Join([]string{
+		"# build output",
+		"build/",
+		"*.o",
+		"!keep.o",
+		"/top.txt",
+		"docs/*.tmp",
+		`\#literal`,
+		"trailing   ",
+	}, "\n")), "")
+	sub, _ := parseIgnore(strings.NewReader("gen/\n!*.o\nvendor/**\n"), "src")
+	rules := append(root, sub...)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"build", true, true},
+		{"build", false, false}, // Directory-only pattern
+		{"src/build", true, true},
+		{"a.o", false, true},
+		{"lib/x/a.o", false, true},
+		{"lib/keep.o", false, false}, // Negated
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false}, // Anchored to the root
+		{"docs/a.tmp", false, true},
+		{"x/docs/a.tmp", false, false},
+		{"#literal", false, true},
+		{"trailing", false, true},
+		{"src/gen", true, true},
+		{"gen", true, false}, // Scoped to src
+		{"src/a.o", false, false},
+		{"srcx/a.o", false, true},
+		{"src/vendor", true, false}, // Only what is inside
+		{"src/vendor/x.go", false, true},
+		{"src/vendor/x/y", true, true},
+	}
+	for _, tt := range tests {
+		if got := ignoreRules(rules).ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestFilterOptions_Match_Doublestar(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter FilterOptions
+		rel    string
+		want   bool
+	}{
+		{"recursive include", FilterOptions{IncludeGlobs: []string{"src/**/*.go"}}, "src/a/b/main.go", true},
+		{"recursive include outside", FilterOptions{IncludeGlobs: []string{"src/**/*.go"}}, "cmd/main.go", false},
+		{"recursive exclude", FilterOptions{ExcludeGlobs: []string{"**/node_modules/**"}}, "web/node_modules/x/y.js", false},
+		{"anchored to root", FilterOptions{IncludeGlobs: []string{"/docs/*.md"}}, "docs/a.md", true},
+		{"base name at any depth", FilterOptions{ExcludeGlobs: []string{"*.log"}}, "a/b/c.log", false},
+		{"braces", FilterOptions{IncludeGlobs: []string{"*.{go,mod}"}}, "a/go.mod", true},
+		{"braces no match", FilterOptions{IncludeGlobs: []string{"*.{go,mod}"}}, "a/go.sum", false},
+		{"case-sensitive", FilterOptions{IncludeGlobs: []string{"*.go"}}, "MAIN.GO", false},
+		{"case-insensitive", FilterOptions{IncludeGlobs: []string{"SRC/*.go"}, IgnoreCase: true}, "src/MAIN.GO", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.match("root/"+tt.rel, tt.rel, 100, nil); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := map[string]string{
+		"*.go":          "*.go",
+		"*.{go,mod}":    "*.go *.mod",
+		"{a,b}/{c,d}":   "a/c a/d b/c b/d",
+		"x{a,b{c,d}}":   "xa xbc xbd",
+		"{single}":      "single",
+		`\{a,b}`:        `\{a,b}`,
+		"{unbalanced":   "{unbalanced",
+		"{,suffix}.txt": ".txt suffix.txt",
+	}
+	for pattern, want := range tests {
+		if got := strings.Join(expandBraces(pattern), " "); got != want {
+			t.Errorf("expandBraces(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestFilterOptions_Match_Regexp(t *testing.T) {
+	filter := FilterOptions{
+		IncludeRegexps: []*regexp.Regexp{regexp.MustCompile(`^logs/.*\.log$`), regexp.MustCompile(`report-\d{4}`)},
+		ExcludeRegexps: []*regexp.Regexp{regexp.MustCompile(`debug`)},
+	}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"logs/app.log", true},
+		{"logs/debug.log", false},
+		{"logs/app.txt", false},
+		{"out/report-2024.pdf", true},
+		{"out/report-24.pdf", false},
+	}
+	for _, tt := range tests {
+		if got := filter.match("root/"+tt.rel, tt.rel, 100, nil); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestFilterOptions_MatchFile(t *testing.T) {
+	dir := t.TempDir()
+	stat := func(name string, mode os.FileMode, age time.Duration) fs.FileInfo {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("data"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	recent := stat("recent.txt", 0644, time.Hour)
+	old := stat("old.sh", 0755, 30*24*time.Hour)
+	hidden := stat(".hidden", 0600, time.Hour)
+	week := time.Now().Add(-7 * 24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		filter FilterOptions
+		info   fs.FileInfo
+		want   bool
+	}{
+		{name: "modified after", filter: FilterOptions{ModifiedAfter: week}, info: recent, want: true},
+		{name: "modified before bound", filter: FilterOptions{ModifiedAfter: week}, info: old, want: false},
+		{name: "modified before", filter: FilterOptions{ModifiedBefore: week}, info: old, want: true},
+		{name: "modified after bound", filter: FilterOptions{ModifiedBefore: week}, info: recent, want: false},
+		{name: "perm exact", filter: FilterOptions{Perm: &PermFilter{Bits: 0644}}, info: recent, want: true},
+		{name: "perm exact mismatch", filter: FilterOptions{Perm: &PermFilter{Bits: 0644}}, info: old, want: false},
+		{name: "perm all", filter: FilterOptions{Perm: &PermFilter{Bits: 0111, Mode: PermAll}}, info: old, want: true},
+		{name: "perm any", filter: FilterOptions{Perm: &PermFilter{Bits: 0022, Mode: PermAny}}, info: hidden, want: false},
+		{name: "hidden", filter: FilterOptions{SkipHidden: true}, info: hidden, want: false},
+		{name: "not hidden", filter: FilterOptions{SkipHidden: true}, info: recent, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.MatchFile(filepath.Join(dir, tt.info.Name()), tt.info); got != tt.want {
+				t.Errorf("MatchFile(%s) = %v, want %v", tt.info.Name(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePermFilter(t *testing.T) {
+	tests := []struct {
+		input string
+		want  PermFilter
+	}{
+		{"644", PermFilter{Bits: 0644}},
+		{"-111", PermFilter{Bits: 0111, Mode: PermAll}},
+		{"/022", PermFilter{Bits: 0022, Mode: PermAny}},
+		{"4755", PermFilter{Bits: 0755 | fs.ModeSetuid}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePermFilter(tt.input)
+		if err != nil {
+			t.Errorf("ParsePermFilter(%q) error: %v", tt.input, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParsePermFilter(%q) = %+v, want %+v", tt.input, *got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "rwx", "888", "17777"} {
+		if _, err := ParsePermFilter(input); err == nil {
+			t.Errorf("ParsePermFilter(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	dir := t.TempDir()
+	stat := func(name string, size int, age time.Duration) fs.FileInfo {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	photo := stat("photo.JPG", 2<<20, time.Hour)
+	thumb := stat("thumb.png", 1000, time.Hour)
+	notes := stat(".notes.txt", 10, 30*24*time.Hour)
+
+	files := []struct {
+		rel  string
+		info fs.FileInfo
+	}{
+		{"photos/photo.JPG", photo},
+		{"raw/thumb.png", thumb},
+		{"docs/.notes.txt", notes},
+	}
+
+	tests := []struct {
+		expr string
+		want []bool // per file
+	}{
+		{`(ext in [.jpg, .png] and size > 1MB) or path ~ "^raw/"`, []bool{true, true, false}},
+		{`ext in [jpg,png] && size <= 1000`, []bool{false, true, false}},
+		{`not hidden and name glob "*.{JPG,txt}"`, []bool{true, false, false}},
+		{`hidden || path glob "photos/**"`, []bool{true, false, true}},
+		{`mtime < 7d`, []bool{false, false, true}},
+		{`age < 1d AND type = file`, []bool{true, true, false}},
+		{`size != 10 and !(name == 'thumb.png')`, []bool{true, false, false}},
+		{`path !~ "\.(png|txt)$" or perm == 0644`, []bool{true, true, true}},
+		{`mtime > 2000-01-01 and mtime < "2200-01-01T00:00:00Z"`, []bool{true, true, true}},
+	}
+
+	for _, tt := range tests {
+		expr, err := ParseExpr(tt.expr, time.Now())
+		if err != nil {
+			t.Errorf("ParseExpr(%q) error: %v", tt.expr, err)
+			continue
+		}
+		for i, f := range files {
+			if got := expr.match(filepath.Join(dir, f.info.Name()), f.rel, f.info.Size(), f.info); got != tt.want[i] {
+				t.Errorf("%q on %s = %v, want %v", tt.expr, f.rel, got, tt.want[i])
+			}
+		}
+	}
+
+	// Without file info, only path and size fields are known
+	expr, _ := ParseExpr("size > 1KB or hidden", time.Now())
+	if !expr.Match("a.bin", 2048, nil) || expr.Match(".a.bin", 10, nil) {
+		t.Error("Match without info: wrong result")
+	}
+
+	// The filter applies the expression after its other criteria
+	filter := FilterOptions{ExcludeExts: []string{".png"}, Expr: expr}
+	if filter.Match("a.png", 2048) || !filter.Match("a.bin", 2048) {
+		t.Error("FilterOptions.Match with Expr: wrong result")
+	}
+}
+
+func TestParseExpr_Errors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		column int
+		msg    string
+	}{
+		{"", 1, "expected field name"},
+		{"size >", 7, "expected value"},
+		{"size > 1XB", 8, "invalid size"},
+		{"nme == x", 1, "unknown field"},
+		{"name > x", 6, "cannot be used with name"},
+		{"size ~ x", 6, "cannot be used with size"},
+		{"(name == x", 1, "unclosed parenthesis"},
+		{"name == x)", 10, `expected "and", "or" or end of expression`},
+		{"ext in [.jpg", 13, `expected "," or "]"`},
+		{`path ~ "("`, 8, "invalid regexp"},
+		{`name == "abc`, 9, "unterminated string"},
+		{"size > 1 & x", 10, "character"},
+		{"mtime > yesterday", 9, "invalid time"},
+		{"mtime < 2999-01-01", 9, "out of range"},
+		{"größe == 1", 1, "unknown field"},
+		{"name == é or", 13, "expected field name"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseExpr(tt.expr, time.Now())
+		exprErr, ok := err.(*ExprError)
+		if !ok {
+			t.Errorf("ParseExpr(%q) error = %v, want *ExprError", tt.expr, err)
+			continue
+		}
+		if exprErr.Column() != tt.column || !strings.Contains(exprErr.Msg, tt.msg) {
+			t.Errorf("ParseExpr(%q) error = %v, want column %d: %s", tt.expr, err, tt.column, tt.msg)
+		}
+	}
+}
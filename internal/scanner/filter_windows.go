@@ -0,0 +1,23 @@
+package scanner
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// owner is not available on Windows, where files have no user and group IDs.
+func owner(info fs.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
+
+// changeTime is not available on Windows.
+func changeTime(info fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// hiddenAttribute reports whether a file has FILE_ATTRIBUTE_HIDDEN.
+func hiddenAttribute(info fs.FileInfo) bool {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
@@ -0,0 +1,96 @@
+package scanner
+
+import (
+	"path"
+	"strings"
+)
+
+// matchPath reports whether a slash-separated path matches a pattern in
+// which "**" as a whole segment matches any number of segments, including
+// none. Other segments are matched as by path.Match, so "*" does not cross
+// slashes.
+func matchPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range len(name) + 1 {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// expandBraces expands the brace groups of a pattern into one pattern per
+// alternative: "*.{go,mod}" becomes "*.go" and "*.mod". Groups may nest. A
+// backslash escapes a brace and unbalanced braces are kept as they are.
+func expandBraces(pattern string) []string {
+	start, depth := -1, 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			if depth--; depth > 0 {
+				continue
+			}
+			var patterns []string
+			for _, alt := range splitAlternatives(pattern[start+1 : i]) {
+				patterns = append(patterns, expandBraces(pattern[:start]+alt+pattern[i+1:])...)
+			}
+			return patterns
+		}
+	}
+	return []string{pattern}
+}
+
+// splitAlternatives splits the inside of a brace group at the commas that
+// are not in a nested group.
+func splitAlternatives(s string) []string {
+	var alts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(alts, s[last:])
+}
@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"io/fs"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+// fileID identifies a file by device and inode.
+type fileID struct {
+	dev, ino uint64
+}
+
+// hardlink is a file with several links, hashed through the first of them.
+type hardlink struct {
+	done   chan struct{} // Closed once result is set
+	result *Result
+}
+
+// linkedFileID returns the identity of a file that has more than one link.
+func linkedFileID(info fs.FileInfo) (fileID, bool) {
+	if n, ok := nlink(info); !ok || n < 2 {
+		return fileID{}, false
+	}
+	dev, ok := deviceID(info)
+	if !ok {
+		return fileID{}, false
+	}
+	ino, ok := inode(info)
+	return fileID{dev, ino}, ok
+}
+
+// hashLinked hashes a file into result like hashFile, unless another link to
+// the same file has been hashed by the scanner. Then its hashes are reused
+// and result.LinkOf is set, waiting for them if they are still in progress.
+// If hashing the other link failed, the file is hashed on its own.
+func (s *Scanner) hashLinked(path string, info fs.FileInfo, workers hasher.Workers, result *Result) {
+	id, ok := linkedFileID(info)
+	if !s.Hardlinks || !ok {
+		s.hashFile(path, workers, result)
+		return
+	}
+
+	s.linksMu.Lock()
+	link, seen := s.links[id]
+	if !seen {
+		link = &hardlink{done: make(chan struct{})}
+		if s.links == nil {
+			s.links = make(map[fileID]*hardlink)
+		}
+		s.links[id] = link
+	}
+	s.linksMu.Unlock()
+
+	if !seen {
+		s.hashFile(path, workers, result)
+		link.result = result
+		close(link.done)
+		return
+	}
+
+	<-link.done
+	if first := link.result; first.Error == nil {
+		result.Hashes = first.Hashes
+		result.Chunks = first.Chunks
+		result.CDCChunks = first.CDCChunks
+		result.LinkOf = first.Path
+		return
+	}
+	s.hashFile(path, workers, result)
+}
+
+// listLinked sets result.LinkOf in a dry run if another link to the same
+// file has been listed, as hashLinked would reuse its hashes.
+func (s *Scanner) listLinked(info fs.FileInfo, result *Result) {
+	id, ok := linkedFileID(info)
+	if !s.Hardlinks || !ok {
+		return
+	}
+
+	s.linksMu.Lock()
+	defer s.linksMu.Unlock()
+	if link, seen := s.links[id]; seen {
+		result.LinkOf = link.result.Path
+		return
+	}
+	link := &hardlink{done: make(chan struct{}), result: result}
+	close(link.done)
+	if s.links == nil {
+		s.links = make(map[fileID]*hardlink)
+	}
+	s.links[id] = link
+}
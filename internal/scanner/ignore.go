@@ -0,0 +1,138 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// gitIgnoreFile is the name of the per-directory ignore files read with
+// FilterOptions.GitIgnore.
+const gitIgnoreFile = ".gitignore"
+
+// ignoreRule is a pattern of an ignore file in gitignore syntax.
+type ignoreRule struct {
+	base     string // Directory of the ignore file, relative to the scan root ("" for the root)
+	pattern  string // Glob without the "!", leading and trailing slashes
+	negate   bool   // Re-include matching paths ("!pattern")
+	dirOnly  bool   // Only match directories ("pattern/")
+	anchored bool   // Match the path below base; otherwise match the name at any depth
+	line     string // The rule as written, and the file it is from, for explanations
+	file     string
+}
+
+// ignoreRules are the rules that apply in a directory, from the outermost
+// ignore file to the innermost. The last matching rule decides.
+type ignoreRules []ignoreRule
+
+// parseIgnore reads the rules of an ignore file in directory base:
+//
+//   - blank lines and lines starting with "#" are skipped
+//   - "!" negates a pattern, re-including paths an earlier rule ignored
+//   - a trailing "/" only matches directories
+//   - a pattern containing a "/" other than at its end is relative to base,
+//     otherwise it matches names at any depth below base
+//   - "**" matches any number of directories, but a trailing "/**" only
+//     matches what is inside a directory, not the directory itself
+//
+// Trailing spaces are ignored unless escaped with a backslash, which also
+// escapes a leading "#" or "!".
+func parseIgnore(r io.Reader, base string) (ignoreRules, error) {
+	var rules ignoreRules
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		line := strings.TrimSuffix(lines.Text(), "\r")
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule := ignoreRule{base: base, line: line}
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if strings.HasSuffix(rule.pattern, "/**") {
+			// "foo/**" is "foo/*/**": at least one segment below foo
+			rule.pattern = strings.TrimSuffix(rule.pattern, "**") + "*/**"
+		}
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, lines.Err()
+}
+
+// loadIgnore reads an ignore file into rules for directory base, returning
+// the rules unchanged if it does not exist. The result does not share
+// storage with rules, so that sibling directories can extend them.
+func loadIgnore(file string, base string, rules ignoreRules) (ignoreRules, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return rules, nil
+	}
+	if err != nil {
+		return rules, err
+	}
+	defer f.Close()
+
+	more, err := parseIgnore(f, base)
+	if err != nil || len(more) == 0 {
+		return rules, err
+	}
+	for i := range more {
+		more[i].file = file
+	}
+	return append(rules[:len(rules):len(rules)], more...), nil
+}
+
+// ignored reports whether a path, slash-separated and relative to the scan
+// root, is ignored by the rules.
+func (rules ignoreRules) ignored(rel string, isDir bool) bool {
+	return rules.ignoredBy(rel, isDir) != nil
+}
+
+// ignoredBy returns the rule that ignores a path, or nil if it is not
+// ignored.
+func (rules ignoreRules) ignoredBy(rel string, isDir bool) *ignoreRule {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(rel, isDir) {
+			if rules[i].negate {
+				return nil
+			}
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func (r *ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if r.anchored {
+		return matchPath(r.pattern, rel)
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
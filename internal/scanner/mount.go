@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// parseMountInfo reads the filesystem type of every mount in the format of
+// /proc/self/mountinfo, keyed by device ID as reported by stat:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+//
+// The third field is the device's major:minor and the type follows the
+// "-" separator after the optional fields.
+func parseMountInfo(r io.Reader) (map[uint64]string, error) {
+	types := make(map[uint64]string)
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		fields := strings.Fields(lines.Text())
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 3 || sep < 0 || sep+1 >= len(fields) {
+			return nil, fmt.Errorf("invalid mountinfo line: %q", lines.Text())
+		}
+
+		major, minor, ok := strings.Cut(fields[2], ":")
+		ma, err1 := strconv.ParseUint(major, 10, 32)
+		mi, err2 := strconv.ParseUint(minor, 10, 32)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid device in mountinfo: %s", fields[2])
+		}
+		types[mkdev(ma, mi)] = fields[sep+1]
+	}
+	return types, lines.Err()
+}
+
+// mkdev encodes a major and minor number as a Linux device ID.
+func mkdev(major, minor uint64) uint64 {
+	return minor&0xff | (major&0xfff)<<8 | (minor&^0xff)<<12 | (major&^0xfff)<<32
+}
+
+// matchFSType reports whether a filesystem type is in the list. FUSE types
+// carry their subtype ("fuse.sshfs"), which "fuse" also matches.
+func matchFSType(fsType string, list []string) bool {
+	for _, t := range list {
+		if fsType == t || strings.HasPrefix(fsType, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// skipFilesystem returns why a file found by the walk is on a filesystem
+// the scanner stays off: another device than the root's with OneFileSystem,
+// or an excluded filesystem type. It returns "" for other files.
+func (w *walker) skipFilesystem(info fs.FileInfo) string {
+	if !w.s.OneFileSystem && w.fsTypes == nil {
+		return ""
+	}
+	dev, ok := deviceID(info)
+	if !ok {
+		return ""
+	}
+	if w.s.OneFileSystem && w.rootDevice != nil && dev != *w.rootDevice {
+		return "on another filesystem"
+	}
+	if w.fsTypes != nil && matchFSType(w.fsTypes[dev], w.s.ExcludeFSTypes) {
+		return "on excluded filesystem type " + w.fsTypes[dev]
+	}
+	return ""
+}
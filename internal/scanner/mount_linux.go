@@ -0,0 +1,13 @@
+package scanner
+
+import "os"
+
+// mountFSTypes returns the filesystem type of every mounted device.
+func mountFSTypes() (map[uint64]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package scanner
+
+import "errors"
+
+// mountFSTypes is only implemented on Linux.
+func mountFSTypes() (map[uint64]string, error) {
+	return nil, errors.New("filesystem types are only available on Linux")
+}
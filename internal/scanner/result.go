@@ -1,11 +1,39 @@
 package scanner
 
+import (
+	"io/fs"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+// FileType is the kind of file a result describes.
+type FileType string
+
+const (
+	TypeFile        FileType = "file"      // Regular file, hashed
+	TypeSymlink     FileType = "symlink"   // Symbolic link, recorded with its target
+	TypeFIFO        FileType = "fifo"      // Named pipe, skipped
+	TypeSocket      FileType = "socket"    // Unix socket, skipped
+	TypeCharDevice  FileType = "chardev"   // Character device, skipped
+	TypeBlockDevice FileType = "blockdev"  // Block device, skipped unless enabled
+	TypeIrregular   FileType = "irregular" // Other non-regular file, skipped
+	TypeDir         FileType = "dir"       // Directory, only reported as excluded
+)
+
 // Result holds the result of scanning a single file.
 type Result struct {
-	Path   string            // File path (relative or absolute based on input)
-	Size   int64             // File size in bytes
-	Hashes map[string]string // Algorithm name -> hash value
-	Error  error             // Error if any (nil on success)
+	Path      string              // File path (relative or absolute based on input)
+	Size      int64               // File size in bytes
+	Mode      fs.FileMode         // File mode bits
+	Type      FileType            // Kind of file (empty for errors)
+	Target    string              // Link target (symlinks only)
+	LinkOf    string              // Hardlink whose hashes were reused, if any
+	Skipped   bool                // Not hashed because of its type
+	Excluded  string              // Why the file or directory was left out (with Scanner.Explain)
+	Hashes    map[string]string   // Algorithm name -> hash value
+	Chunks    map[string][]string // Algorithm name -> per-chunk hash values (chunk mode only)
+	CDCChunks []hasher.CDCChunk   // Content-defined chunks (CDC mode only)
+	Error     error               // Error if any (nil on success)
 }
 
 // IsError returns true if this result represents an error.
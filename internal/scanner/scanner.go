@@ -2,15 +2,18 @@ package scanner
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	pathpkg "path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/Virace/fast-hasher/internal/hasher"
+	"github.com/Virace/fast-hasher/pkg/fastcdc"
 )
 
 // ErrorStrategy defines how to handle errors during scanning.
@@ -29,8 +32,35 @@ type Scanner struct {
 	Filter       *FilterOptions // File filter options
 	Hashers      []hasher.Hasher
 	OnError      ErrorStrategy
-	Recursive    bool // Whether to scan directories recursively
-	AbsolutePath bool // Whether to output absolute paths
+	Recursive    bool             // Whether to scan directories recursively
+	AbsolutePath bool             // Whether to output absolute paths
+	ChunkSize    int64            // If positive, also hash each block of this many bytes
+	CDC          *fastcdc.Options // If set, also hash content-defined chunks
+	Sample       *hasher.Sample   // If set, hash only this sample of each file
+	IO           hasher.IOOptions // How files are read (buffer size, mmap, page cache hints)
+	Devices      *DeviceLimits    // If set, also limit concurrency per storage device
+	Order        ReadOrder        // Order in which files are read (default: walk order)
+	Symlinks     SymlinkPolicy    // How symbolic links are handled (default: follow links to files)
+	Hardlinks    bool             // Hash files with several hardlinks once (Linux only)
+	BlockDevices bool             // Hash block devices (disk images) instead of skipping them
+	DryRun       bool             // Report the files that would be hashed without reading them
+	Explain      bool             // Also report the files and directories left out, with Result.Excluded set
+
+	// Limits on walking directories in ScanDir. Depths count from 1 for the
+	// entries of the scanned directory; pruned directories are not read.
+	MaxDepth        int      // Do not scan deeper than this many levels (0 = no limit)
+	MinDepth        int      // Skip files less than this many levels deep
+	ExcludeDirNames []string // Do not enter directories whose name matches one of these globs
+	SkipHiddenDirs  bool     // Do not enter hidden directories
+
+	// Filesystems that ScanDir does not enter (Linux only)
+	OneFileSystem  bool     // Stay on the device of each scanned directory
+	ExcludeFSTypes []string // Filesystem types to skip, e.g. proc, sysfs, nfs, fuse
+
+	devicesMu sync.Mutex
+	devices   map[uint64]*device // Device ID -> device, shared by all scans
+	linksMu   sync.Mutex
+	links     map[fileID]*hardlink // Hardlinked files hashed so far, shared by all scans
 }
 
 // NewScanner creates a new scanner with default settings.
@@ -40,15 +70,27 @@ func NewScanner(hashers []hasher.Hasher) *Scanner {
 		Hashers:   hashers,
 		OnError:   SkipOnError,
 		Recursive: true,
+		Hardlinks: true,
 	}
 }
 
 // ScanFile scans a single file and returns its hash result.
+// Large files may be hashed in parallel segments using up to Workers
+// goroutines, unless they are on a spinning disk with per-device limits.
 func (s *Scanner) ScanFile(path string) *Result {
-	// Get file info
-	info, err := os.Stat(path)
-	if err != nil {
-		return &Result{Path: path, Error: err}
+	workers := hasher.NewWorkers(s.Workers)
+	var result *Result
+	s.run([]string{path}, workers, func(path string, workers hasher.Workers) {
+		result = s.scanFile(path, workers)
+	})
+	return result
+}
+
+// scanFile scans a single file while holding one of the given workers.
+func (s *Scanner) scanFile(path string, workers hasher.Workers) *Result {
+	info, result := s.lstat(path)
+	if info == nil {
+		return result // Error, or a skipped symlink
 	}
 
 	if info.IsDir() {
@@ -56,25 +98,57 @@ func (s *Scanner) ScanFile(path string) *Result {
 	}
 
 	// Apply filter
-	if s.Filter != nil && !s.Filter.Match(path, info.Size()) {
-		return nil // Filtered out
+	if s.Filter != nil {
+		if reason := s.Filter.reject(path, path, info.Size(), info); reason != "" {
+			if s.Explain {
+				return s.excludedResult(path, info, reason)
+			}
+			return nil // Filtered out
+		}
 	}
 
-	// Compute hashes
-	hashes, err := hasher.HashFile(path, s.Hashers)
+	return s.processInfo(path, info, workers)
+}
 
-	outputPath := path
+// excludedResult reports a file or directory left out for the given reason.
+// Directories are reported with size 0.
+func (s *Scanner) excludedResult(path string, info fs.FileInfo, reason string) *Result {
+	result := &Result{
+		Path:     s.outputPath(path),
+		Mode:     info.Mode(),
+		Type:     fileType(info.Mode()),
+		Excluded: reason,
+	}
+	if !info.IsDir() {
+		result.Size = info.Size()
+	}
+	return result
+}
+
+// outputPath returns the path to report for a file.
+func (s *Scanner) outputPath(path string) string {
 	if s.AbsolutePath {
 		if abs, err := filepath.Abs(path); err == nil {
-			outputPath = abs
+			return abs
 		}
 	}
+	return path
+}
 
-	return &Result{
-		Path:   outputPath,
-		Size:   info.Size(),
-		Hashes: hashes,
-		Error:  err,
+// hashFile computes the hashes of a file (or of a sample of it) into result,
+// plus its chunk hashes in chunk or content-defined chunking mode. Chunks are
+// computed in a single sequential pass; otherwise large files may be split
+// into segments hashed by idle workers.
+func (s *Scanner) hashFile(path string, workers hasher.Workers, result *Result) {
+	switch {
+	case s.Sample != nil:
+		result.Hashes, result.Error = hasher.HashFileSample(path, s.Hashers, *s.Sample, s.IO)
+	case s.CDC != nil:
+		result.Hashes, result.CDCChunks, result.Error = hasher.HashFileCDC(path, s.Hashers, *s.CDC, s.IO)
+	case s.ChunkSize > 0:
+		result.Hashes, result.Chunks, result.Error = hasher.HashFileChunks(path, s.Hashers, s.ChunkSize, s.IO)
+	default:
+		result.Hashes, result.Error = hasher.HashFileParallel(path, s.Hashers, workers, s.IO)
 	}
 }
 
@@ -85,32 +159,14 @@ func (s *Scanner) ScanFiles(paths []string) <-chan *Result {
 	go func() {
 		defer close(results)
 
-		sem := make(chan struct{}, s.Workers)
-		var wg sync.WaitGroup
-
-		for _, path := range paths {
-			path := path // capture loop variable
-
-			sem <- struct{}{} // acquire
-			wg.Add(1)
-
-			go func() {
-				defer func() {
-					<-sem // release
-					wg.Done()
-				}()
-
-				result := s.ScanFile(path)
-				if result != nil {
-					results <- result
-					if result.Error != nil && s.OnError == FailOnError {
-						return
-					}
-				}
-			}()
-		}
-
-		wg.Wait()
+		// Workers are shared between files and the segments of large files
+		workers := hasher.NewWorkers(s.Workers)
+		s.run(paths, workers, func(path string, workers hasher.Workers) {
+			result := s.scanFile(path, workers)
+			if result != nil {
+				results <- result
+			}
+		})
 	}()
 
 	return results
@@ -124,99 +180,284 @@ func (s *Scanner) ScanDir(dir string) <-chan *Result {
 		defer close(results)
 
 		// Collect all files first
-		var files []string
-		walkFn := func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				if s.OnError == FailOnError {
-					return err
-				}
-				// Send error result
-				results <- &Result{Path: path, Error: err}
-				return nil
-			}
+		files, err := s.walk(dir, func(r *Result) { results <- r })
+		if err != nil {
+			results <- &Result{Path: dir, Error: err}
+			return
+		}
 
-			if d.IsDir() {
-				if !s.Recursive && path != dir {
-					return fs.SkipDir
-				}
-				return nil
+		// Process files concurrently, sharing workers with large-file segments
+		workers := hasher.NewWorkers(s.Workers)
+		s.run(files, workers, func(path string, workers hasher.Workers) {
+			result := s.processFile(path, workers)
+			if result != nil {
+				results <- result
 			}
+		})
+	}()
 
-			// Get file info for filtering
-			info, err := d.Info()
-			if err != nil {
-				if s.OnError == FailOnError {
-					return err
-				}
-				results <- &Result{Path: path, Error: err}
-				return nil
-			}
+	return results
+}
 
-			// Apply filter
-			if s.Filter != nil && !s.Filter.Match(path, info.Size()) {
-				return nil
-			}
+// ListFiles walks a directory and returns the paths of all files that would be
+// hashed by ScanDir, in walk order. Unreadable entries are skipped unless
+// OnError is FailOnError, in which case the first error is returned.
+func (s *Scanner) ListFiles(dir string) ([]string, error) {
+	return s.walk(dir, func(*Result) {})
+}
 
-			files = append(files, path)
-			return nil
+// walk collects the files under dir that pass the recursion, depth, symlink
+// and filter settings, in lexical order within each directory. Errors for
+// individual entries are passed to report when skipping errors, and so are
+// the files and directories left out with Explain.
+func (s *Scanner) walk(dir string, report func(*Result)) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &walker{s: s, report: report}
+	if s.OneFileSystem {
+		if dev, ok := deviceID(info); ok {
+			w.rootDevice = &dev
 		}
+	}
+	if len(s.ExcludeFSTypes) > 0 {
+		if w.fsTypes, err = mountFSTypes(); err != nil {
+			return nil, err
+		}
+	}
 
-		if err := filepath.WalkDir(dir, walkFn); err != nil {
-			results <- &Result{Path: dir, Error: err}
-			return
+	if !info.IsDir() {
+		err = w.addFile(dir, dir, info)
+	} else {
+		var rules ignoreRules
+		if s.Filter != nil && s.Filter.IgnoreFile != "" {
+			file := s.Filter.IgnoreFile
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			if rules, err = loadIgnore(file, "", nil); err != nil {
+				return nil, err
+			}
 		}
+		err = w.walkDir(dir, "", info, nil, rules)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w.files, nil
+}
 
-		// Process files concurrently
-		sem := make(chan struct{}, s.Workers)
-		var wg sync.WaitGroup
+// walker collects the files of a directory tree.
+type walker struct {
+	s          *Scanner
+	report     func(*Result)
+	files      []string
+	rootDevice *uint64           // Device of the root, with OneFileSystem
+	fsTypes    map[uint64]string // Device ID -> filesystem type, with ExcludeFSTypes
+}
 
-		for _, path := range files {
-			path := path
+// ancestor is a directory being walked.
+type ancestor struct {
+	path  string
+	rel   string      // Slash-separated path relative to the root
+	info  fs.FileInfo // For loop detection
+	rules ignoreRules // Ignore rules for its entries
+}
 
-			sem <- struct{}{}
-			wg.Add(1)
+// fail reports an error for path, or returns it if the scan stops on errors.
+func (w *walker) fail(path string, err error) error {
+	if w.s.OnError == FailOnError {
+		return err
+	}
+	w.report(&Result{Path: path, Error: err})
+	return nil
+}
 
-			go func() {
-				defer func() {
-					<-sem
-					wg.Done()
-				}()
+// exclude reports a file or directory the walk leaves out, with Explain.
+func (w *walker) exclude(path string, info fs.FileInfo, reason string) {
+	if w.s.Explain {
+		w.report(w.s.excludedResult(path, info, reason))
+	}
+}
 
-				result := s.processFile(path)
-				if result != nil {
-					results <- result
-				}
-			}()
+// walkDir visits the entries of a directory, which are subject to the
+// ignore rules of its parent and its own .gitignore. A directory that is one
+// of its own ancestors, through a symlink or bind mount, is reported as a
+// loop. Directories on filesystems the scanner stays off are skipped.
+func (w *walker) walkDir(path, rel string, info fs.FileInfo, ancestors []ancestor, rules ignoreRules) error {
+	if reason := w.skipFilesystem(info); reason != "" {
+		w.exclude(path, info, reason)
+		return nil
+	}
+	for _, a := range ancestors {
+		if os.SameFile(a.info, info) {
+			return w.fail(path, fmt.Errorf("%w: %s is %s", ErrLoop, path, a.path))
 		}
+	}
 
-		wg.Wait()
-	}()
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return w.fail(path, err)
+	}
 
-	return results
+	if w.s.Filter != nil && w.s.Filter.GitIgnore {
+		file := filepath.Join(path, gitIgnoreFile)
+		if rules, err = loadIgnore(file, rel, rules); err != nil {
+			if err := w.fail(file, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	ancestors = append(ancestors, ancestor{path, rel, info, rules})
+	for _, entry := range entries {
+		if err := w.visit(filepath.Join(path, entry.Name()), entry, ancestors); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// processFile processes a single file (used internally, assumes filtering is done).
-func (s *Scanner) processFile(path string) *Result {
-	info, err := os.Stat(path)
+// visit handles one directory entry.
+func (w *walker) visit(path string, entry fs.DirEntry, ancestors []ancestor) error {
+	symlink := entry.Type()&fs.ModeSymlink != 0
+	if symlink && w.s.Symlinks == SkipSymlinks {
+		if info, err := entry.Info(); err == nil {
+			w.exclude(path, info, "symlink skipped")
+		}
+		return nil
+	}
+
+	// Get file info for filtering, of the target of followed links
+	var info fs.FileInfo
+	var err error
+	if symlink && w.s.Symlinks != RecordSymlinks {
+		info, err = os.Stat(path)
+	} else {
+		info, err = entry.Info()
+	}
 	if err != nil {
-		return &Result{Path: path, Error: err}
+		return w.fail(path, err)
 	}
 
-	hashes, err := hasher.HashFile(path, s.Hashers)
+	parent := &ancestors[len(ancestors)-1]
+	rel := entry.Name()
+	if parent.rel != "" {
+		rel = parent.rel + "/" + rel
+	}
+	if rule := parent.rules.ignoredBy(rel, info.IsDir()); rule != nil {
+		w.exclude(path, info, fmt.Sprintf("ignored by %q in %s", rule.line, rule.file))
+		return nil
+	}
 
-	outputPath := path
-	if s.AbsolutePath {
-		if abs, err := filepath.Abs(path); err == nil {
-			outputPath = abs
+	depth := len(ancestors)
+	if info.IsDir() {
+		if symlink && w.s.Symlinks == FollowFileSymlinks {
+			w.exclude(path, info, "symlink to a directory not followed")
+			return nil
 		}
+		if reason := w.pruneDir(path, rel, entry.Name(), info, depth); reason != "" {
+			w.exclude(path, info, reason)
+			return nil
+		}
+		return w.walkDir(path, rel, info, ancestors, parent.rules)
+	}
+	if depth < w.s.MinDepth {
+		w.exclude(path, info, fmt.Sprintf("less than %d levels deep", w.s.MinDepth))
+		return nil
 	}
+	return w.addFile(path, rel, info)
+}
 
-	return &Result{
-		Path:   outputPath,
-		Size:   info.Size(),
-		Hashes: hashes,
-		Error:  err,
+// pruneDir returns why a directory at the given depth is not walked, by the
+// recursion, depth, name, hidden directory and filter settings, or "" if it
+// is walked.
+func (w *walker) pruneDir(path, rel, name string, info fs.FileInfo, depth int) string {
+	if !w.s.Recursive {
+		return "not recursive"
+	}
+	if w.s.MaxDepth > 0 && depth >= w.s.MaxDepth {
+		return fmt.Sprintf("deeper than %d levels", w.s.MaxDepth)
+	}
+	if w.s.SkipHiddenDirs && isHidden(path, info) {
+		return "hidden directory"
+	}
+	for _, pattern := range w.s.ExcludeDirNames {
+		for _, p := range expandBraces(pattern) {
+			if m, _ := pathpkg.Match(p, name); m {
+				return "excluded directory name " + pattern
+			}
+		}
+	}
+	if f := w.s.Filter; f != nil {
+		if name == ".git" && f.GitIgnore {
+			return "git directory"
+		}
+		if f.excludesDir(path, rel) {
+			return "matches an exclude pattern"
+		}
+	}
+	return ""
+}
+
+// addFile adds a file if it passes the filesystem settings and the filter.
+func (w *walker) addFile(path, rel string, info fs.FileInfo) error {
+	if reason := w.skipFilesystem(info); reason != "" {
+		w.exclude(path, info, reason)
+		return nil
+	}
+	if w.s.Filter != nil {
+		if reason := w.s.Filter.reject(path, rel, info.Size(), info); reason != "" {
+			w.exclude(path, info, reason)
+			return nil
+		}
+	}
+	w.files = append(w.files, path)
+	return nil
+}
+
+// processFile processes a single file (used internally, assumes filtering is done).
+func (s *Scanner) processFile(path string, workers hasher.Workers) *Result {
+	info, result := s.lstat(path)
+	if info == nil {
+		return result
+	}
+	return s.processInfo(path, info, workers)
+}
+
+// processInfo hashes a file, records it if it is a symlink, or reports it
+// as skipped if it is a special file that is not hashed.
+func (s *Scanner) processInfo(path string, info fs.FileInfo, workers hasher.Workers) *Result {
+	kind := fileType(info.Mode())
+	if kind == TypeSymlink {
+		return s.symlinkResult(path, info)
+	}
+
+	result := &Result{
+		Path: s.outputPath(path),
+		Size: info.Size(),
+		Mode: info.Mode(),
+		Type: kind,
+	}
+	if !s.hashable(kind) {
+		result.Skipped = true
+		return result
+	}
+	if kind == TypeBlockDevice {
+		size, err := deviceSize(path)
+		if err != nil {
+			return &Result{Path: path, Error: err}
+		}
+		result.Size = size
+	}
+	if s.DryRun {
+		s.listLinked(info, result)
+	} else {
+		s.hashLinked(path, info, workers, result)
 	}
+	return result
 }
 
 // ScanFromReader reads file paths from a reader (one per line) and scans them.
@@ -0,0 +1,118 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+func TestScanner_SpecialFiles(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	fifo := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+
+	hashers, _ := hasher.Parse("md5")
+	s := NewScanner(hashers)
+	done := make(chan []*Result)
+	go func() {
+		var results []*Result
+		for r := range s.ScanDir(dir) {
+			results = append(results, r)
+		}
+		done <- results
+	}()
+
+	// Opening the FIFO would block until a writer appears
+	select {
+	case results := <-done:
+		skipped := 0
+		for _, r := range results {
+			if r.IsError() {
+				t.Errorf("%s: %v", r.Path, r.Error)
+			}
+			if r.Skipped {
+				skipped++
+				if r.Path != fifo || r.Type != TypeFIFO || r.Hashes != nil {
+					t.Errorf("skipped %+v, want the FIFO", r)
+				}
+			}
+		}
+		if len(results) != 7 || skipped != 1 {
+			t.Errorf("got %d results with %d skipped, want 7 with 1", len(results), skipped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan blocked on the FIFO")
+	}
+
+	if r := s.ScanFile("/dev/null"); r == nil || !r.Skipped || r.Type != TypeCharDevice {
+		t.Errorf("ScanFile(/dev/null) = %+v, want a skipped character device", r)
+	}
+}
+
+func TestScanner_PhysicalOrderFIFO(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	if err := syscall.Mkfifo(filepath.Join(dir, "fifo"), 0644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+
+	hashers, _ := hasher.Parse("md5")
+	s := NewScanner(hashers)
+	s.Order = PhysicalOrder
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range s.ScanDir(dir) {
+			n++
+		}
+		done <- n
+	}()
+
+	// Finding the physical offset must not open the FIFO
+	select {
+	case n := <-done:
+		if n != 7 {
+			t.Errorf("got %d results, want 7", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("physical order blocked on the FIFO")
+	}
+}
+
+func TestFilterOptions_MatchFile_Owner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	hour := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		filter FilterOptions
+		want   bool
+	}{
+		{name: "owner", filter: FilterOptions{Owners: []uint32{uid + 1, uid}}, want: true},
+		{name: "other owner", filter: FilterOptions{Owners: []uint32{uid + 1}}, want: false},
+		{name: "group", filter: FilterOptions{Groups: []uint32{gid}}, want: true},
+		{name: "other group", filter: FilterOptions{Owners: []uint32{uid}, Groups: []uint32{gid + 1}}, want: false},
+		{name: "changed after", filter: FilterOptions{ChangedAfter: hour}, want: true},
+		{name: "changed before", filter: FilterOptions{ChangedBefore: hour}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.MatchFile(path, info); got != tt.want {
+			t.Errorf("%s: MatchFile = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,18 @@
 package scanner
 
 import (
+	"errors"
+	"hash"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Virace/fast-hasher/internal/hasher"
 )
@@ -57,6 +65,30 @@ func TestScanner_ScanFile(t *testing.T) {
 	}
 }
 
+func TestScanner_ScanFile_Chunks(t *testing.T) {
+	dir := t.TempDir()
+	testFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	hashers, _ := hasher.Parse("md5")
+	s := NewScanner(hashers)
+
+	if result := s.ScanFile(testFile); result.Chunks != nil {
+		t.Errorf("Chunks = %v without ChunkSize, want nil", result.Chunks)
+	}
+
+	s.ChunkSize = 4
+	result := s.ScanFile(testFile)
+	if result.Error != nil {
+		t.Fatalf("ScanFile error: %v", result.Error)
+	}
+	if n := len(result.Chunks["md5"]); n != 3 {
+		t.Errorf("got %d md5 chunks for 11 bytes, want 3", n)
+	}
+}
+
 func TestScanner_ScanFile_WithFilter(t *testing.T) {
 	dir := t.TempDir()
 	testFile := filepath.Join(dir, "test.txt")
@@ -216,3 +248,645 @@ func TestScanner_AbsolutePath(t *testing.T) {
 		t.Errorf("Expected absolute path, got %s", result.Path)
 	}
 }
+
+func TestScanner_ListFiles(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+
+	s := NewScanner(nil)
+	s.Filter = &FilterOptions{IncludeExts: []string{".txt"}}
+
+	files, err := s.ListFiles(dir)
+	if err != nil {
+		t.Fatalf("ListFiles error: %v", err)
+	}
+	if len(files) != 4 {
+		t.Errorf("Expected 4 files, got %d: %v", len(files), files)
+	}
+
+	s.Recursive = false
+	files, err = s.ListFiles(dir)
+	if err != nil {
+		t.Fatalf("ListFiles error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 files, got %d: %v", len(files), files)
+	}
+}
+
+// concurrencyHasher records how many of its hashes are in use at once.
+type concurrencyHasher struct {
+	active, peak *atomic.Int32
+}
+
+func (h concurrencyHasher) Name() string              { return "concurrency" }
+func (h concurrencyHasher) OutputSize() int           { return 1 }
+func (h concurrencyHasher) Encoding() hasher.Encoding { return hasher.Hex }
+
+func (h concurrencyHasher) New() hash.Hash {
+	n := h.active.Add(1)
+	for {
+		peak := h.peak.Load()
+		if n <= peak || h.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	return &concurrencyHash{h}
+}
+
+type concurrencyHash struct{ concurrencyHasher }
+
+func (h *concurrencyHash) Write(p []byte) (int, error) {
+	time.Sleep(5 * time.Millisecond)
+	return len(p), nil
+}
+
+func (h *concurrencyHash) Sum(b []byte) []byte {
+	h.active.Add(-1)
+	return append(b, 0)
+}
+
+func (h *concurrencyHash) Reset()         {}
+func (h *concurrencyHash) Size() int      { return 1 }
+func (h *concurrencyHash) BlockSize() int { return 1 }
+
+func TestScanner_DeviceLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestFiles(t, tmpDir)
+	if info, err := os.Stat(tmpDir); err != nil {
+		t.Fatal(err)
+	} else if _, ok := deviceID(info); !ok {
+		t.Skip("device IDs not supported")
+	}
+	defer func(f func(uint64) (bool, bool)) { checkRotational = f }(checkRotational)
+
+	tests := []struct {
+		name       string
+		rotational bool
+		limits     *DeviceLimits
+		maxPeak    int32
+		minPeak    int32
+	}{
+		{"disabled", true, nil, 4, 2},
+		{"spinning disk", true, DefaultDeviceLimits(), 1, 1},
+		{"ssd unlimited", false, DefaultDeviceLimits(), 4, 2},
+		{"ssd limited", false, &DeviceLimits{NonRotational: 2}, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkRotational = func(uint64) (bool, bool) { return tt.rotational, true }
+			h := concurrencyHasher{new(atomic.Int32), new(atomic.Int32)}
+			s := NewScanner([]hasher.Hasher{h})
+			s.Workers = 4
+			s.Devices = tt.limits
+
+			count := 0
+			for result := range s.ScanDir(tmpDir) {
+				if result.Error != nil {
+					t.Errorf("unexpected error for %s: %v", result.Path, result.Error)
+				}
+				count++
+			}
+			if count != 6 {
+				t.Errorf("got %d results, want 6", count)
+			}
+			if peak := h.peak.Load(); peak < tt.minPeak || peak > tt.maxPeak {
+				t.Errorf("peak concurrency %d, want %d-%d", peak, tt.minPeak, tt.maxPeak)
+			}
+		})
+	}
+}
+
+func TestScanner_ReadOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestFiles(t, tmpDir)
+
+	hashers, _ := hasher.Parse("md5")
+	for _, order := range []ReadOrder{InodeOrder, PhysicalOrder} {
+		s := NewScanner(hashers)
+		s.Workers = 1 // results arrive in dispatch order
+		s.Order = order
+
+		var paths []string
+		var keys []orderKey
+		for result := range s.ScanDir(tmpDir) {
+			info, err := os.Stat(result.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, result.Path)
+			keys = append(keys, readOrderKey(result.Path, info, order))
+		}
+		if len(paths) != 6 {
+			t.Fatalf("order %d: got %d results, want 6", order, len(paths))
+		}
+		for i := 1; i < len(keys); i++ {
+			if compareOrderKeys(keys[i-1], keys[i]) > 0 {
+				t.Errorf("order %d: %s read before %s", order, paths[i-1], paths[i])
+			}
+		}
+	}
+}
+
+func TestSortByKey(t *testing.T) {
+	q := &deviceQueue{paths: []string{"missing", "c", "a", "b", "d"}}
+	q.sortByKey([]*orderKey{
+		nil,
+		{noPhysical: true, inode: 1},
+		{physical: 100, inode: 9},
+		{physical: 200, inode: 2},
+		{device: 1, physical: 1},
+	})
+	want := []string{"a", "b", "c", "d", "missing"}
+	if strings.Join(q.paths, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", q.paths, want)
+	}
+}
+
+func TestParseReadOrder(t *testing.T) {
+	for s, want := range map[string]ReadOrder{"": WalkOrder, "walk": WalkOrder, "Inode": InodeOrder, "physical": PhysicalOrder} {
+		if got, err := ParseReadOrder(s); err != nil || got != want {
+			t.Errorf("ParseReadOrder(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseReadOrder("random"); err == nil {
+		t.Error("ParseReadOrder(random): expected error, got nil")
+	}
+	if err := PhysicalOrder.Validate(); (err == nil) != fiemapSupported {
+		t.Errorf("PhysicalOrder.Validate() = %v, supported = %v", err, fiemapSupported)
+	}
+}
+
+func TestScanner_Symlinks(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	if err := os.Symlink("file1.txt", filepath.Join(dir, "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	os.Symlink("..", filepath.Join(dir, "subdir", "up"))
+	os.Symlink("deep", filepath.Join(dir, "subdir", "deeplink"))
+
+	hashers, _ := hasher.Parse("md5")
+	scan := func(policy SymlinkPolicy) (files map[string]*Result, errs []*Result) {
+		s := NewScanner(hashers)
+		s.Symlinks = policy
+		files = make(map[string]*Result)
+		for r := range s.ScanDir(dir) {
+			if r.IsError() {
+				errs = append(errs, r)
+				continue
+			}
+			rel, _ := filepath.Rel(dir, r.Path)
+			files[filepath.ToSlash(rel)] = r
+		}
+		return files, errs
+	}
+
+	// Files: the file link is hashed, the directory links are not descended
+	files, errs := scan(FollowFileSymlinks)
+	if len(files) != 7 || len(errs) != 0 {
+		t.Errorf("files: got %d files and %d errors, want 7 and 0", len(files), len(errs))
+	}
+	if r := files["link.txt"]; r == nil || r.Type != TypeFile || r.Hashes["md5"] != files["file1.txt"].Hashes["md5"] {
+		t.Errorf("files: link.txt = %+v", r)
+	}
+
+	// Follow: the file link is hashed, the directory link is descended and
+	// the link back to the root is a loop
+	files, errs = scan(FollowSymlinks)
+	if len(files) != 8 {
+		t.Errorf("follow: got %d files, want 8", len(files))
+	}
+	if r := files["link.txt"]; r == nil || r.Type != TypeFile || r.Hashes["md5"] != files["file1.txt"].Hashes["md5"] {
+		t.Errorf("follow: link.txt = %+v", r)
+	}
+	if files["subdir/deeplink/file6.txt"] == nil {
+		t.Error("follow: linked directory not descended")
+	}
+	if len(errs) != 1 || !errors.Is(errs[0].Error, ErrLoop) {
+		t.Errorf("follow: errors = %v, want one loop", errs)
+	}
+
+	// Skip: links are ignored
+	files, errs = scan(SkipSymlinks)
+	if len(files) != 6 || len(errs) != 0 {
+		t.Errorf("skip: got %d files and %d errors, want 6 and 0", len(files), len(errs))
+	}
+
+	// Record: links are reported with their targets, without hashes
+	files, errs = scan(RecordSymlinks)
+	if len(files) != 9 || len(errs) != 0 {
+		t.Errorf("record: got %d files and %d errors, want 9 and 0", len(files), len(errs))
+	}
+	if r := files["subdir/up"]; r == nil || r.Type != TypeSymlink || r.Target != ".." || r.Hashes != nil {
+		t.Errorf("record: subdir/up = %+v", r)
+	}
+
+	// Explicit files follow the policy too
+	s := NewScanner(hashers)
+	s.Symlinks = SkipSymlinks
+	if r := s.ScanFile(filepath.Join(dir, "link.txt")); r != nil {
+		t.Errorf("skip: ScanFile(link.txt) = %+v, want nil", r)
+	}
+	s.Symlinks = RecordSymlinks
+	if r := s.ScanFile(filepath.Join(dir, "link.txt")); r == nil || r.Target != "file1.txt" {
+		t.Errorf("record: ScanFile(link.txt) = %+v", r)
+	}
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	for s, want := range map[string]SymlinkPolicy{"": FollowFileSymlinks, "files": FollowFileSymlinks, "follow": FollowSymlinks, "Skip": SkipSymlinks, "record": RecordSymlinks} {
+		if got, err := ParseSymlinkPolicy(s); err != nil || got != want {
+			t.Errorf("ParseSymlinkPolicy(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseSymlinkPolicy("hardlink"); err == nil {
+		t.Error("ParseSymlinkPolicy(hardlink): expected error, got nil")
+	}
+}
+
+func TestScanner_Hardlinks(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("hardlink detection is only implemented on Linux")
+	}
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	for _, name := range []string{"link1.txt", "subdir/link2.txt"} {
+		if err := os.Link(filepath.Join(dir, "file1.txt"), filepath.Join(dir, name)); err != nil {
+			t.Skipf("hardlinks not supported: %v", err)
+		}
+	}
+
+	hashers, _ := hasher.Parse("md5")
+	links := map[string]bool{"file1.txt": true, "link1.txt": true, "link2.txt": true}
+
+	for _, enabled := range []bool{true, false} {
+		s := NewScanner(hashers)
+		s.Hardlinks = enabled
+		firsts, linked := 0, 0
+		var sum string
+		for r := range s.ScanDir(dir) {
+			if r.IsError() {
+				t.Fatalf("ScanDir: %v", r.Error)
+			}
+			if !links[filepath.Base(r.Path)] {
+				continue
+			}
+			if sum == "" {
+				sum = r.Hashes["md5"]
+			} else if r.Hashes["md5"] != sum {
+				t.Errorf("%s: md5 = %s, want %s", r.Path, r.Hashes["md5"], sum)
+			}
+			if r.LinkOf != "" {
+				linked++
+			} else {
+				firsts++
+			}
+		}
+		if enabled && (firsts != 1 || linked != 2) {
+			t.Errorf("hardlinks: %d hashed and %d reused, want 1 and 2", firsts, linked)
+		}
+		if !enabled && (firsts != 3 || linked != 0) {
+			t.Errorf("no hardlinks: %d hashed and %d reused, want 3 and 0", firsts, linked)
+		}
+	}
+
+	// A dry run marks the links a real run would not read again
+	s := NewScanner(nil)
+	s.DryRun = true
+	linked := 0
+	for r := range s.ScanDir(dir) {
+		if r.LinkOf != "" {
+			if !links[filepath.Base(r.Path)] || !links[filepath.Base(r.LinkOf)] {
+				t.Errorf("dry run: %s linked to %s", r.Path, r.LinkOf)
+			}
+			linked++
+		}
+	}
+	if linked != 2 {
+		t.Errorf("dry run: %d links marked, want 2", linked)
+	}
+}
+
+func TestParseMountInfo(t *testing.T) {
+	input := strings.Join([]string{
+		"23 28 0:22 / /proc rw,relatime - proc proc rw",
+		"28 1 254:0 / / rw,relatime shared:1 master:2 - ext4 /dev/vda rw",
+		"40 28 0:45 / /mnt/ssh rw - fuse.sshfs host:/ rw",
+		"41 28 259:1048576 / /mnt/big rw - xfs /dev/nvme0n1p1 rw",
+	}, "\n")
+	types, err := parseMountInfo(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseMountInfo: %v", err)
+	}
+	want := map[uint64]string{0x16: "proc", 0xfe00: "ext4", 0x2d: "fuse.sshfs", mkdev(259, 1048576): "xfs"}
+	for dev, fsType := range want {
+		if types[dev] != fsType {
+			t.Errorf("types[%#x] = %q, want %q", dev, types[dev], fsType)
+		}
+	}
+
+	if _, err := parseMountInfo(strings.NewReader("23 28 0:22 / /proc rw")); err == nil {
+		t.Error("parseMountInfo(no separator): expected error, got nil")
+	}
+}
+
+func TestMatchFSType(t *testing.T) {
+	list := []string{"proc", "fuse"}
+	for fsType, want := range map[string]bool{"proc": true, "fuse": true, "fuse.sshfs": true, "fuseblk": false, "ext4": false, "": false} {
+		if got := matchFSType(fsType, list); got != want {
+			t.Errorf("matchFSType(%q) = %v, want %v", fsType, got, want)
+		}
+	}
+}
+
+func TestScanner_Filesystems(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("filesystem boundaries are only detected on Linux")
+	}
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	// A symlink into procfs crosses onto another filesystem
+	if err := os.Symlink("/proc/self/fdinfo", filepath.Join(dir, "proc")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	hashers, _ := hasher.Parse("md5")
+	list := func(configure func(s *Scanner)) []string {
+		s := NewScanner(hashers)
+		s.Symlinks = FollowSymlinks
+		configure(s)
+		files, err := s.ListFiles(dir)
+		if err != nil {
+			t.Fatalf("ListFiles: %v", err)
+		}
+		return files
+	}
+
+	// The process has open files, so the linked directory is not empty
+	if files := list(func(*Scanner) {}); len(files) <= 6 {
+		t.Fatalf("linked /proc/self/fdinfo not descended: %v", files)
+	}
+	if files := list(func(s *Scanner) { s.OneFileSystem = true }); len(files) != 6 {
+		t.Errorf("OneFileSystem: got %d files, want 6: %v", len(files), files)
+	}
+	if files := list(func(s *Scanner) { s.ExcludeFSTypes = []string{"proc"} }); len(files) != 6 {
+		t.Errorf("ExcludeFSTypes: got %d files, want 6: %v", len(files), files)
+	}
+}
+
+func TestFileType(t *testing.T) {
+	tests := map[fs.FileMode]FileType{
+		0644:                              TypeFile,
+		fs.ModeSymlink | 0777:             TypeSymlink,
+		fs.ModeNamedPipe | 0644:           TypeFIFO,
+		fs.ModeSocket | 0755:              TypeSocket,
+		fs.ModeDevice | fs.ModeCharDevice: TypeCharDevice,
+		fs.ModeDevice | 0660:              TypeBlockDevice,
+		fs.ModeIrregular:                  TypeIrregular,
+	}
+	for mode, want := range tests {
+		if got := fileType(mode); got != want {
+			t.Errorf("fileType(%v) = %s, want %s", mode, got, want)
+		}
+	}
+
+	s := NewScanner(nil)
+	if s.hashable(TypeBlockDevice) || s.hashable(TypeFIFO) || !s.hashable(TypeFile) {
+		t.Error("only regular files are hashable by default")
+	}
+	s.BlockDevices = true
+	if !s.hashable(TypeBlockDevice) {
+		t.Error("block devices not hashable with BlockDevices")
+	}
+}
+
+func TestScanner_IgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	files := map[string]string{
+		".gitignore":          "*.log\nsubdir/deep/\n",
+		"subdir/.gitignore":   "*.md\n",
+		".git/HEAD":           "ref",
+		"project.ignore":      "file2.txt\n",
+		"subdir/deep/x.txt":   "pruned",
+		"subdir/deep/y/z.txt": "pruned",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashers, _ := hasher.Parse("md5")
+	s := NewScanner(hashers)
+	s.Filter = &FilterOptions{GitIgnore: true, IgnoreFile: "project.ignore"}
+	list, err := s.ListFiles(dir)
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+
+	var got []string
+	for _, path := range list {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{".gitignore", "file1.txt", "project.ignore", "subdir/.gitignore", "subdir/file4.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ListFiles = %v, want %v", got, want)
+	}
+}
+
+func TestScanner_IgnoreDirectoryContents(t *testing.T) {
+	// As in git, "foo/**" ignores what is inside foo but not foo itself, so
+	// a negated rule can re-include a file in it. A re-included directory
+	// does not re-include its contents.
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore": "foo/**\n!foo/keep\n!foo/kept\n",
+		"foo/a":      "a",
+		"foo/keep":   "keep",
+		"foo/kept/x": "x",
+		"foo/sub/b":  "b",
+		"foo2/k":     "k",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewScanner(nil)
+	s.Filter = &FilterOptions{GitIgnore: true}
+	list, err := s.ListFiles(dir)
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+
+	var got []string
+	for _, path := range list {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{".gitignore", "foo/keep", "foo2/k"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ListFiles = %v, want %v", got, want)
+	}
+}
+
+func TestScanner_ExcludeDirs(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+
+	// The pattern matches the directory but not subdir/deep/file6.txt, which
+	// is only left out because the directory is not walked
+	hashers, _ := hasher.Parse("md5")
+	s := NewScanner(hashers)
+	s.Filter = &FilterOptions{ExcludeGlobs: []string{"subdir/deep"}}
+	files, err := s.ListFiles(dir)
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	if len(files) != 5 {
+		t.Errorf("got %d files, want 5: %v", len(files), files)
+	}
+}
+
+func TestScanner_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+
+	expr, err := ParseExpr(`ext == .txt and path glob "subdir/**"`, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScanner(nil)
+	s.DryRun = true
+	s.Filter = &FilterOptions{Expr: expr}
+
+	var paths []string
+	for r := range s.ScanDir(dir) {
+		if r.IsError() {
+			t.Fatalf("%s: %v", r.Path, r.Error)
+		}
+		if r.Hashes != nil || r.Size == 0 {
+			t.Errorf("%s: hashes = %v, size = %d; want no hashes and the size", r.Path, r.Hashes, r.Size)
+		}
+		rel, _ := filepath.Rel(dir, r.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	slices.Sort(paths)
+	if want := []string{"subdir/deep/file6.txt", "subdir/file4.txt"}; !slices.Equal(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestScanner_WalkLimits(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	for _, name := range []string{".cache", "node_modules"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "file.txt"), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Reading a pruned directory would report the loop
+		if err := os.Symlink("..", filepath.Join(dir, name, "loop")); err != nil {
+			t.Skipf("symlink: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		setup func(s *Scanner)
+		want  []string
+	}{
+		{
+			name:  "max depth",
+			setup: func(s *Scanner) { s.MaxDepth = 1 },
+			want:  []string{"file1.txt", "file2.txt", "file3.log"},
+		},
+		{
+			name: "depth range",
+			setup: func(s *Scanner) {
+				s.MinDepth, s.MaxDepth = 2, 2
+				s.ExcludeDirNames = []string{"{.cache,node_*}"}
+			},
+			want: []string{"subdir/file4.txt", "subdir/file5.md"},
+		},
+		{
+			name: "excluded names and hidden directories",
+			setup: func(s *Scanner) {
+				s.ExcludeDirNames = []string{"node_modules", "deep"}
+				s.SkipHiddenDirs = true
+			},
+			want: []string{"file1.txt", "file2.txt", "file3.log", "subdir/file4.txt", "subdir/file5.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(nil)
+			s.OnError = FailOnError
+			tt.setup(s)
+			files, err := s.ListFiles(dir)
+			if err != nil {
+				t.Fatalf("ListFiles: %v", err)
+			}
+			var got []string
+			for _, f := range files {
+				rel, _ := filepath.Rel(dir, f)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanner_Explain(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, ".fhashignore"), []byte("*.md\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScanner(nil)
+	s.DryRun = true
+	s.Explain = true
+	s.MaxDepth = 2
+	s.Filter = &FilterOptions{ExcludeExts: []string{".log"}, IgnoreFile: ".fhashignore"}
+
+	got := map[string]string{}
+	for r := range s.ScanDir(dir) {
+		if r.IsError() {
+			t.Fatalf("%s: %v", r.Path, r.Error)
+		}
+		rel, _ := filepath.Rel(dir, r.Path)
+		got[filepath.ToSlash(rel)] = r.Excluded
+	}
+	want := map[string]string{
+		".fhashignore":     "",
+		"file1.txt":        "",
+		"file2.txt":        "",
+		"file3.log":        "extension excluded",
+		"subdir/file4.txt": "",
+		"subdir/file5.md":  `ignored by "*.md" in ` + filepath.Join(dir, ".fhashignore"),
+		"subdir/deep":      "deeper than 2 levels",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("results = %v, want %v", got, want)
+	}
+
+	// Files given explicitly are explained too
+	if r := s.ScanFile(filepath.Join(dir, "file3.log")); r == nil || r.Excluded != "extension excluded" {
+		t.Errorf("ScanFile = %+v, want excluded", r)
+	}
+}
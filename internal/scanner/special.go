@@ -0,0 +1,46 @@
+package scanner
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// fileType returns the kind of file a mode describes.
+func fileType(mode fs.FileMode) FileType {
+	switch {
+	case mode.IsRegular():
+		return TypeFile
+	case mode.IsDir():
+		return TypeDir
+	case mode&fs.ModeSymlink != 0:
+		return TypeSymlink
+	case mode&fs.ModeNamedPipe != 0:
+		return TypeFIFO
+	case mode&fs.ModeSocket != 0:
+		return TypeSocket
+	case mode&fs.ModeCharDevice != 0:
+		return TypeCharDevice
+	case mode&fs.ModeDevice != 0:
+		return TypeBlockDevice
+	default:
+		return TypeIrregular
+	}
+}
+
+// hashable reports whether files of a type are hashed. Reading pipes,
+// sockets and character devices could block forever or never end, so only
+// regular files are, and block devices if enabled.
+func (s *Scanner) hashable(kind FileType) bool {
+	return kind == TypeFile || (kind == TypeBlockDevice && s.BlockDevices)
+}
+
+// deviceSize returns the size of a block device, which stat reports as 0.
+func deviceSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.Seek(0, io.SeekEnd)
+}
@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// SymlinkPolicy defines how symbolic links are handled. Directories passed
+// to ScanDir are always followed; the policy applies to all other links.
+type SymlinkPolicy int
+
+const (
+	// FollowFileSymlinks hashes the targets of links to files but does not
+	// descend into links to directories.
+	FollowFileSymlinks SymlinkPolicy = iota
+	// FollowSymlinks hashes the targets of links to files and descends into
+	// links to directories, reporting links back to an ancestor as loops.
+	FollowSymlinks
+	// SkipSymlinks ignores links.
+	SkipSymlinks
+	// RecordSymlinks reports links with their target instead of hashing them.
+	RecordSymlinks
+)
+
+// ErrLoop is returned for a directory that contains itself through a
+// symlink or bind mount.
+var ErrLoop = errors.New("filesystem loop")
+
+// ParseSymlinkPolicy parses a symlink policy name: files, follow, skip or
+// record.
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "files":
+		return FollowFileSymlinks, nil
+	case "follow":
+		return FollowSymlinks, nil
+	case "skip":
+		return SkipSymlinks, nil
+	case "record":
+		return RecordSymlinks, nil
+	}
+	return FollowFileSymlinks, fmt.Errorf("unknown symlink policy: %s (use files, follow, skip or record)", s)
+}
+
+// lstat returns the info of the file at path under the symlink policy: the
+// link itself when recording links, its target when following them, and
+// nil when skipping them. Errors are returned as a result.
+func (s *Scanner) lstat(path string) (fs.FileInfo, *Result) {
+	info, err := os.Lstat(path)
+	if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		switch s.Symlinks {
+		case SkipSymlinks:
+			return nil, nil
+		case FollowFileSymlinks, FollowSymlinks:
+			info, err = os.Stat(path)
+		}
+	}
+	if err != nil {
+		return nil, &Result{Path: path, Error: err}
+	}
+	return info, nil
+}
+
+// symlinkResult records a link and its target.
+func (s *Scanner) symlinkResult(path string, info fs.FileInfo) *Result {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return &Result{Path: path, Error: err}
+	}
+	return &Result{
+		Path:   s.outputPath(path),
+		Size:   info.Size(),
+		Mode:   info.Mode(),
+		Type:   TypeSymlink,
+		Target: target,
+	}
+}
@@ -0,0 +1,76 @@
+package torrent
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Marshal returns the bencode encoding of v.
+// Supported types are int, int64, string, []byte, []string, []any and map[string]any.
+// Dictionary keys are written in sorted (raw byte) order as required by BEP 3.
+func Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeValue(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case int:
+		encodeInt(buf, int64(val))
+	case int64:
+		encodeInt(buf, val)
+	case string:
+		encodeBytes(buf, []byte(val))
+	case []byte:
+		encodeBytes(buf, val)
+	case []string:
+		buf.WriteByte('l')
+		for _, s := range val {
+			encodeBytes(buf, []byte(s))
+		}
+		buf.WriteByte('e')
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range val {
+			if err := encodeValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('d')
+		for _, k := range keys {
+			encodeBytes(buf, []byte(k))
+			if err := encodeValue(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %T", v)
+	}
+	return nil
+}
+
+func encodeInt(buf *bytes.Buffer, n int64) {
+	buf.WriteByte('i')
+	buf.WriteString(strconv.FormatInt(n, 10))
+	buf.WriteByte('e')
+}
+
+func encodeBytes(buf *bytes.Buffer, b []byte) {
+	buf.WriteString(strconv.Itoa(len(b)))
+	buf.WriteByte(':')
+	buf.Write(b)
+}
@@ -0,0 +1,124 @@
+package torrent
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// BlockSize is the size of a BitTorrent v2 merkle tree leaf (16 KiB).
+const BlockSize = 16 * 1024
+
+// merkleFile computes the BitTorrent v2 (BEP 52) merkle tree of a single file.
+// Only the leaves of the current piece and the completed piece layer are kept
+// in memory, so arbitrarily large files can be processed.
+type merkleFile struct {
+	blocksPerPiece int
+
+	leaf     hash.Hash
+	leafFill int
+	leaves   [][sha256.Size]byte // leaf hashes of the current piece
+	layer    [][sha256.Size]byte // completed piece layer hashes
+	size     int64
+}
+
+func newMerkleFile(pieceLength int64) *merkleFile {
+	return &merkleFile{
+		blocksPerPiece: int(pieceLength / BlockSize),
+		leaf:           sha256.New(),
+	}
+}
+
+// Write adds file data to the tree. It never returns an error.
+func (m *merkleFile) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		n := min(BlockSize-m.leafFill, len(p))
+		m.leaf.Write(p[:n])
+		m.leafFill += n
+		p = p[n:]
+		if m.leafFill == BlockSize {
+			m.finishLeaf()
+		}
+	}
+	m.size += int64(total)
+	return total, nil
+}
+
+func (m *merkleFile) finishLeaf() {
+	var sum [sha256.Size]byte
+	m.leaf.Sum(sum[:0])
+	m.leaf.Reset()
+	m.leafFill = 0
+
+	m.leaves = append(m.leaves, sum)
+	if len(m.leaves) == m.blocksPerPiece {
+		m.layer = append(m.layer, merkleRoot(m.leaves, m.blocksPerPiece, [sha256.Size]byte{}))
+		m.leaves = m.leaves[:0]
+	}
+}
+
+// Finish returns the pieces root of the file and, for files larger than one
+// piece, the concatenated piece layer hashes. Empty files have no root.
+func (m *merkleFile) Finish() (root []byte, pieceLayer []byte) {
+	if m.size == 0 {
+		return nil, nil
+	}
+	if m.leafFill > 0 {
+		m.finishLeaf()
+	}
+
+	// The whole file fits in one piece: the tree is only as wide as needed.
+	if len(m.layer) == 0 {
+		r := merkleRoot(m.leaves, nextPowerOfTwo(len(m.leaves)), [sha256.Size]byte{})
+		return r[:], nil
+	}
+
+	if len(m.leaves) > 0 {
+		m.layer = append(m.layer, merkleRoot(m.leaves, m.blocksPerPiece, [sha256.Size]byte{}))
+		m.leaves = m.leaves[:0]
+	}
+	if len(m.layer) == 1 {
+		return m.layer[0][:], nil
+	}
+
+	// Remaining piece layer nodes are the roots of all-zero leaf subtrees.
+	pad := merkleRoot(nil, m.blocksPerPiece, [sha256.Size]byte{})
+	r := merkleRoot(m.layer, nextPowerOfTwo(len(m.layer)), pad)
+
+	pieceLayer = make([]byte, 0, len(m.layer)*sha256.Size)
+	for _, h := range m.layer {
+		pieceLayer = append(pieceLayer, h[:]...)
+	}
+	return r[:], pieceLayer
+}
+
+// merkleRoot computes the root of a balanced binary tree with width leaves,
+// using pad for the leaves beyond len(nodes). width must be a power of two.
+func merkleRoot(nodes [][sha256.Size]byte, width int, pad [sha256.Size]byte) [sha256.Size]byte {
+	level := make([][sha256.Size]byte, width)
+	n := copy(level, nodes)
+	for i := n; i < width; i++ {
+		level[i] = pad
+	}
+
+	var buf [2 * sha256.Size]byte
+	for len(level) > 1 {
+		next := level[:len(level)/2]
+		for i := range next {
+			copy(buf[:sha256.Size], level[2*i][:])
+			copy(buf[sha256.Size:], level[2*i+1][:])
+			next[i] = sha256.Sum256(buf[:])
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// nextPowerOfTwo returns the smallest power of two >= n (and at least 1).
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,369 @@
+// Package torrent builds BitTorrent v1, v2 and hybrid metainfo from files on disk.
+package torrent
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Version selects which BitTorrent metainfo format to produce.
+type Version int
+
+const (
+	// V1 produces a classic BEP 3 torrent (SHA-1 piece list).
+	V1 Version = iota + 1
+	// V2 produces a BEP 52 torrent (per-file SHA-256 merkle trees).
+	V2
+	// Hybrid produces a torrent that is valid as both v1 and v2.
+	Hybrid
+)
+
+// ParseVersion parses "v1", "v2" or "hybrid".
+func ParseVersion(s string) (Version, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "v1", "1":
+		return V1, nil
+	case "v2", "2":
+		return V2, nil
+	case "hybrid":
+		return Hybrid, nil
+	}
+	return 0, fmt.Errorf("unknown torrent version: %s (available: v1, v2, hybrid)", s)
+}
+
+func (v Version) hasV1() bool { return v == V1 || v == Hybrid }
+func (v Version) hasV2() bool { return v == V2 || v == Hybrid }
+
+const (
+	// MinPieceLength is the smallest piece length allowed by BEP 52.
+	MinPieceLength = BlockSize
+	// MaxAutoPieceLength caps the piece length chosen by AutoPieceLength.
+	MaxAutoPieceLength = 16 * 1024 * 1024
+	// targetPieces is the piece count AutoPieceLength aims to stay below.
+	targetPieces = 1500
+)
+
+// Options configures torrent creation.
+type Options struct {
+	Version     Version
+	PieceLength int64  // Piece length in bytes (0 = choose automatically)
+	Name        string // Torrent name (default: base name of the root)
+}
+
+// Torrent holds the result of building a torrent.
+type Torrent struct {
+	Info        map[string]any    // The info dictionary
+	PieceLayers map[string][]byte // v2 piece layers keyed by pieces root
+	InfoHashV1  []byte            // SHA-1 of the bencoded info (v1 and hybrid)
+	InfoHashV2  []byte            // SHA-256 of the bencoded info (v2 and hybrid)
+}
+
+// file is a single file that is part of the torrent.
+type file struct {
+	path  string   // Path on disk
+	parts []string // Path components relative to the root
+	size  int64
+}
+
+// AutoPieceLength returns a power-of-two piece length suitable for the total size.
+func AutoPieceLength(totalSize int64) int64 {
+	pieceLength := int64(MinPieceLength)
+	for pieceLength < MaxAutoPieceLength && totalSize/pieceLength > targetPieces {
+		pieceLength *= 2
+	}
+	return pieceLength
+}
+
+// Build creates a torrent for root. If root is a directory, paths lists the
+// files under it to include (e.g. from scanner.ListFiles); if root is a
+// regular file, paths is ignored and a single-file torrent is built.
+func Build(root string, paths []string, opts Options) (*Torrent, error) {
+	if !opts.Version.hasV1() && !opts.Version.hasV2() {
+		return nil, fmt.Errorf("invalid torrent version")
+	}
+
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.Name
+	if name == "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.Base(abs)
+	}
+
+	var files []file
+	if rootInfo.IsDir() {
+		files, err = collectFiles(root, paths)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files to include in torrent: %s", root)
+		}
+	} else {
+		files = []file{{path: root, size: rootInfo.Size()}}
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = AutoPieceLength(total)
+	}
+	if pieceLength < MinPieceLength || pieceLength&(pieceLength-1) != 0 {
+		return nil, fmt.Errorf("piece length must be a power of two of at least %d bytes: %d", MinPieceLength, pieceLength)
+	}
+
+	b := &builder{
+		version:     opts.Version,
+		pieceLength: pieceLength,
+		pieceHash:   sha1.New(),
+		pieceLayers: make(map[string][]byte),
+		fileTree:    make(map[string]any),
+	}
+	if err := b.addFiles(files, rootInfo.IsDir()); err != nil {
+		return nil, err
+	}
+
+	return b.finish(name, rootInfo.IsDir())
+}
+
+// collectFiles converts scanner paths into torrent files sorted by path
+// components, which is the order required for the v2 file tree and the
+// matching v1 file list of hybrid torrents.
+func collectFiles(root string, paths []string) ([]file, error) {
+	files := make([]file, 0, len(paths))
+	for _, p := range paths {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, file{
+			path:  p,
+			parts: strings.Split(filepath.ToSlash(rel), "/"),
+			size:  info.Size(),
+		})
+	}
+
+	slices.SortFunc(files, func(a, b file) int {
+		return slices.Compare(a.parts, b.parts)
+	})
+	return files, nil
+}
+
+// builder accumulates v1 pieces and v2 file trees while files are read.
+type builder struct {
+	version     Version
+	pieceLength int64
+
+	// v1 state: pieces span file boundaries
+	pieceHash hash.Hash
+	pieceFill int64
+	pieces    []byte
+	v1Files   []any
+
+	// v2 state
+	pieceLayers map[string][]byte
+	fileTree    map[string]any
+
+	// single-file torrents
+	singleLength int64
+	singleRoot   map[string]any
+}
+
+func (b *builder) addFiles(files []file, multi bool) error {
+	for _, f := range files {
+		// Hybrid torrents align every file with data to a piece boundary
+		// with a pad file before it, so that v1 pieces never span two
+		// files. As in libtorrent, empty files get no padding, and none
+		// follows the last file.
+		if b.version == Hybrid && multi && f.size > 0 && b.pieceFill > 0 {
+			padding := b.pieceLength - b.pieceFill
+			b.writePieces(make([]byte, padding))
+			b.v1Files = append(b.v1Files, map[string]any{
+				"attr":   "p",
+				"length": padding,
+				"path":   []string{".pad", strconv.FormatInt(padding, 10)},
+			})
+		}
+
+		if err := b.addFile(f, multi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) addFile(f file, multi bool) error {
+	fh, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writers []io.Writer
+	if b.version.hasV1() {
+		writers = append(writers, pieceWriter{b})
+	}
+	var tree *merkleFile
+	if b.version.hasV2() {
+		tree = newMerkleFile(b.pieceLength)
+		writers = append(writers, tree)
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), fh)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	if n != f.size {
+		return fmt.Errorf("file changed while reading: %s", f.path)
+	}
+
+	if !multi {
+		b.singleLength = f.size
+	} else if b.version.hasV1() {
+		b.v1Files = append(b.v1Files, map[string]any{
+			"length": f.size,
+			"path":   f.parts,
+		})
+	}
+
+	if tree != nil {
+		entry := map[string]any{"length": f.size}
+		root, layer := tree.Finish()
+		if root != nil {
+			entry["pieces root"] = root
+		}
+		if layer != nil {
+			b.pieceLayers[string(root)] = layer
+		}
+
+		leaf := map[string]any{"": entry}
+		if multi {
+			insertFileTree(b.fileTree, f.parts, leaf)
+		} else {
+			b.singleRoot = leaf
+		}
+	}
+	return nil
+}
+
+// insertFileTree places leaf into the nested v2 file tree at parts.
+func insertFileTree(tree map[string]any, parts []string, leaf map[string]any) {
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := tree[part].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			tree[part] = child
+		}
+		tree = child
+	}
+	tree[parts[len(parts)-1]] = leaf
+}
+
+// pieceWriter feeds data into the v1 piece hashes.
+type pieceWriter struct{ b *builder }
+
+func (w pieceWriter) Write(p []byte) (int, error) {
+	w.b.writePieces(p)
+	return len(p), nil
+}
+
+func (b *builder) writePieces(p []byte) {
+	for len(p) > 0 {
+		n := min(b.pieceLength-b.pieceFill, int64(len(p)))
+		b.pieceHash.Write(p[:n])
+		b.pieceFill += n
+		p = p[n:]
+		if b.pieceFill == b.pieceLength {
+			b.pieces = b.pieceHash.Sum(b.pieces)
+			b.pieceHash.Reset()
+			b.pieceFill = 0
+		}
+	}
+}
+
+func (b *builder) finish(name string, multi bool) (*Torrent, error) {
+	info := map[string]any{
+		"name":         name,
+		"piece length": b.pieceLength,
+	}
+
+	if b.version.hasV1() {
+		if b.pieceFill > 0 {
+			b.pieces = b.pieceHash.Sum(b.pieces)
+		}
+		info["pieces"] = b.pieces
+		if multi {
+			info["files"] = b.v1Files
+		} else {
+			info["length"] = b.singleLength
+		}
+	}
+
+	if b.version.hasV2() {
+		info["meta version"] = 2
+		if multi {
+			info["file tree"] = b.fileTree
+		} else {
+			info["file tree"] = map[string]any{name: b.singleRoot}
+		}
+	}
+
+	encoded, err := Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Torrent{Info: info}
+	if b.version.hasV1() {
+		sum := sha1.Sum(encoded)
+		t.InfoHashV1 = sum[:]
+	}
+	if b.version.hasV2() {
+		sum := sha256.Sum256(encoded)
+		t.InfoHashV2 = sum[:]
+		t.PieceLayers = b.pieceLayers
+	}
+	return t, nil
+}
+
+// MarshalTorrent returns the bencoded .torrent file containing the info
+// dictionary and, for v2 and hybrid torrents, the piece layers.
+func (t *Torrent) MarshalTorrent() ([]byte, error) {
+	meta := map[string]any{
+		"created by": "fhash",
+		"info":       t.Info,
+	}
+	if len(t.PieceLayers) > 0 {
+		layers := make(map[string]any, len(t.PieceLayers))
+		for root, layer := range t.PieceLayers {
+			layers[root] = layer
+		}
+		meta["piece layers"] = layers
+	}
+	return Marshal(meta)
+}
@@ -0,0 +1,335 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{name: "int", input: 42, want: "i42e"},
+		{name: "negative int64", input: int64(-3), want: "i-3e"},
+		{name: "string", input: "spam", want: "4:spam"},
+		{name: "bytes", input: []byte{0x00, 0xff}, want: "2:\x00\xff"},
+		{name: "list", input: []any{"spam", 1}, want: "l4:spami1ee"},
+		{name: "string list", input: []string{"a", "bc"}, want: "l1:a2:bce"},
+		{
+			name:  "dict keys sorted",
+			input: map[string]any{"zeta": 1, "alpha": "x", "": 2},
+			want:  "d0:i2e5:alpha1:x4:zetai1ee",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Marshal(3.14); err == nil {
+		t.Error("Marshal(float) expected error, got nil")
+	}
+}
+
+// referenceRoot computes a v2 pieces root by materializing every leaf.
+func referenceRoot(data []byte) []byte {
+	var leaves [][sha256.Size]byte
+	for i := 0; i < len(data); i += BlockSize {
+		end := min(i+BlockSize, len(data))
+		leaves = append(leaves, sha256.Sum256(data[i:end]))
+	}
+	root := merkleRoot(leaves, nextPowerOfTwo(len(leaves)), [sha256.Size]byte{})
+	return root[:]
+}
+
+func TestMerkleFile(t *testing.T) {
+	const pieceLength = 4 * BlockSize
+
+	sizes := []int{
+		1,
+		BlockSize,
+		BlockSize + 1,
+		3 * BlockSize,
+		pieceLength,
+		pieceLength + 1,
+		5*pieceLength + 100,
+	}
+
+	for _, size := range sizes {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i % 253)
+		}
+
+		m := newMerkleFile(pieceLength)
+		m.Write(data)
+		root, layer := m.Finish()
+
+		if want := referenceRoot(data); !bytes.Equal(root, want) {
+			t.Errorf("size %d: root %x, want %x", size, root, want)
+		}
+
+		numPieces := (size + pieceLength - 1) / pieceLength
+		if numPieces <= 1 {
+			if layer != nil {
+				t.Errorf("size %d: unexpected piece layer for single piece", size)
+			}
+			continue
+		}
+		if len(layer) != numPieces*sha256.Size {
+			t.Errorf("size %d: piece layer has %d bytes, want %d", size, len(layer), numPieces*sha256.Size)
+		}
+	}
+
+	m := newMerkleFile(pieceLength)
+	if root, layer := m.Finish(); root != nil || layer != nil {
+		t.Error("empty file should have no pieces root")
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string][]byte) []string {
+	t.Helper()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestBuild_SingleFileV1(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("abcdefgh"), 5000) // 40000 bytes, 3 pieces of 16 KiB
+	paths := writeFiles(t, dir, map[string][]byte{"file.bin": data})
+
+	tr, err := Build(paths[0], nil, Options{Version: V1, PieceLength: MinPieceLength})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	var pieces []byte
+	for i := 0; i < len(data); i += MinPieceLength {
+		end := min(i+MinPieceLength, len(data))
+		sum := sha1.Sum(data[i:end])
+		pieces = append(pieces, sum[:]...)
+	}
+
+	if got := tr.Info["pieces"].([]byte); !bytes.Equal(got, pieces) {
+		t.Errorf("pieces mismatch")
+	}
+	if got := tr.Info["length"].(int64); got != int64(len(data)) {
+		t.Errorf("length = %d, want %d", got, len(data))
+	}
+	if tr.Info["name"] != "file.bin" {
+		t.Errorf("name = %v, want file.bin", tr.Info["name"])
+	}
+	if tr.InfoHashV2 != nil {
+		t.Error("v1 torrent should not have a v2 infohash")
+	}
+
+	encoded, _ := Marshal(tr.Info)
+	if want := sha1.Sum(encoded); !bytes.Equal(tr.InfoHashV1, want[:]) {
+		t.Errorf("infohash = %x, want %x", tr.InfoHashV1, want)
+	}
+}
+
+func TestBuild_HybridDirectory(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "release")
+	files := map[string][]byte{
+		"b.txt":        []byte("second file"),
+		"a.txt":        bytes.Repeat([]byte{1}, MinPieceLength+10),
+		"sub/c.bin":    bytes.Repeat([]byte{2}, 100),
+		"sub/empty.md": {},
+	}
+	paths := writeFiles(t, root, files)
+
+	tr, err := Build(root, paths, Options{Version: Hybrid, PieceLength: MinPieceLength})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if tr.Info["name"] != "release" {
+		t.Errorf("name = %v, want release", tr.Info["name"])
+	}
+	if tr.Info["meta version"] != 2 {
+		t.Errorf("meta version = %v, want 2", tr.Info["meta version"])
+	}
+	if len(tr.InfoHashV1) != sha1.Size || len(tr.InfoHashV2) != sha256.Size {
+		t.Fatalf("unexpected infohash lengths: %d, %d", len(tr.InfoHashV1), len(tr.InfoHashV2))
+	}
+
+	// v1 files are sorted by path and a pad file aligns every file with data
+	// to a piece boundary; trailing empty files are not padded
+	v1Files := tr.Info["files"].([]any)
+	var order []string
+	for _, f := range v1Files {
+		entry := f.(map[string]any)
+		if entry["attr"] == "p" {
+			order = append(order, "pad")
+			continue
+		}
+		parts := entry["path"].([]string)
+		order = append(order, filepath.Join(parts...))
+	}
+	want := []string{"a.txt", "pad", "b.txt", "pad", filepath.Join("sub", "c.bin"), filepath.Join("sub", "empty.md")}
+	if len(order) != len(want) {
+		t.Fatalf("v1 files = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("v1 files = %v, want %v", order, want)
+		}
+	}
+
+	// a.txt spans two pieces; b.txt is padded to one piece and sub/c.bin
+	// ends in the last one
+	pieces := tr.Info["pieces"].([]byte)
+	if len(pieces) != 4*sha1.Size {
+		t.Errorf("got %d pieces, want 4", len(pieces)/sha1.Size)
+	}
+
+	// v2 file tree and piece layers
+	tree := tr.Info["file tree"].(map[string]any)
+	a := tree["a.txt"].(map[string]any)[""].(map[string]any)
+	if got, want := a["pieces root"].([]byte), referenceRoot(files["a.txt"]); !bytes.Equal(got, want) {
+		t.Errorf("a.txt pieces root = %x, want %x", got, want)
+	}
+	if _, ok := tr.PieceLayers[string(a["pieces root"].([]byte))]; !ok {
+		t.Error("missing piece layer for a.txt")
+	}
+	empty := tree["sub"].(map[string]any)["empty.md"].(map[string]any)[""].(map[string]any)
+	if _, ok := empty["pieces root"]; ok {
+		t.Error("empty file should not have a pieces root")
+	}
+
+	if _, err := tr.MarshalTorrent(); err != nil {
+		t.Errorf("MarshalTorrent failed: %v", err)
+	}
+}
+
+// TestBuild_KnownInfoHashes checks infohashes against vectors computed
+// independently from BEP 3, 47 and 52, with pad files placed as libtorrent
+// (and so qBittorrent) places them in hybrid torrents.
+func TestBuild_KnownInfoHashes(t *testing.T) {
+	single := map[string][]byte{"file.bin": bytes.Repeat([]byte("abcdefgh"), 5000)}
+	// Empty files in the middle and at the end of the hybrid v1 file list
+	// must not be padded
+	release := map[string][]byte{
+		"a.txt":        bytes.Repeat([]byte{1}, MinPieceLength+10),
+		"b.empty":      {},
+		"b.txt":        []byte("second file"),
+		"sub/c.bin":    bytes.Repeat([]byte{2}, 100),
+		"sub/empty.md": {},
+		"z.txt":        {},
+	}
+	var ramp []byte
+	for i := 0; i < 300*256; i++ {
+		ramp = append(ramp, byte(i))
+	}
+	set := map[string][]byte{"big.bin": ramp, "small.txt": []byte("hello")}
+
+	tests := []struct {
+		name        string
+		root        string
+		files       map[string][]byte
+		pieceLength int64
+		version     Version
+		v1, v2      string
+	}{
+		{"single v1", "file.bin", single, MinPieceLength, V1, "98b8e0b9f25cbea176aa4d37c1702069e4961a2e", ""},
+		{"single v2", "file.bin", single, MinPieceLength, V2, "", "a23766a930d1969b9f66fa57526310978ec3f1bb01460711ede6f42450deef9a"},
+		{"single hybrid", "file.bin", single, MinPieceLength, Hybrid, "094cdc5896fc6956e3143aab19604cc552900bc8", "88fd89b730fc88b83d10416173e454e52e52b17029f521bf4b72adeee71e1e6a"},
+		{"multi v1", "release", release, MinPieceLength, V1, "66db817b72dfff3c6527d86ba578171fd5905621", ""},
+		{"multi v2", "release", release, MinPieceLength, V2, "", "1a1015d2347cac11abf8acdd255e3b252ae6a3814f812d84e6790a4a5e87ae4c"},
+		{"multi hybrid", "release", release, MinPieceLength, Hybrid, "1a3e0a80fd801a541a4afb967a7029fe277ac11e", "4e5a322031129ad9bba4f1dcbf0f067a834b653a26d5332cbbd641138cee0c9b"},
+		{"multi-piece v1", "set", set, 2 * MinPieceLength, V1, "230ba5bea88ce89372ed966e0134c36451f56bd0", ""},
+		{"multi-piece v2", "set", set, 2 * MinPieceLength, V2, "", "4915919dcc9799546dabb74cc0892c7a5cd6685382233cb4c65893a7deae26d8"},
+		{"multi-piece hybrid", "set", set, 2 * MinPieceLength, Hybrid, "639d6eb9ca83fd6c72ace479e3c66507b0c1233b", "f567e82406761c24c58f0c31f14623b7e26784603c1994551169e58690b03cd4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, tt.root)
+			var paths []string
+			if _, isFile := tt.files[tt.root]; isFile {
+				writeFiles(t, dir, tt.files)
+			} else {
+				paths = writeFiles(t, root, tt.files)
+			}
+
+			tr, err := Build(root, paths, Options{Version: tt.version, PieceLength: tt.pieceLength})
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			if got := hex.EncodeToString(tr.InfoHashV1); got != tt.v1 {
+				t.Errorf("v1 infohash = %s, want %s", got, tt.v1)
+			}
+			if got := hex.EncodeToString(tr.InfoHashV2); got != tt.v2 {
+				t.Errorf("v2 infohash = %s, want %s", got, tt.v2)
+			}
+		})
+	}
+}
+
+func TestBuild_InvalidPieceLength(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeFiles(t, dir, map[string][]byte{"f": []byte("x")})
+
+	for _, pl := range []int64{1024, 3 * MinPieceLength} {
+		if _, err := Build(paths[0], nil, Options{Version: V2, PieceLength: pl}); err == nil {
+			t.Errorf("piece length %d: expected error, got nil", pl)
+		}
+	}
+}
+
+func TestAutoPieceLength(t *testing.T) {
+	tests := []struct {
+		size int64
+		want int64
+	}{
+		{0, MinPieceLength},
+		{1024 * 1024, MinPieceLength},
+		{1 << 30, 1 << 20},
+		{1 << 50, MaxAutoPieceLength},
+	}
+	for _, tt := range tests {
+		if got := AutoPieceLength(tt.size); got != tt.want {
+			t.Errorf("AutoPieceLength(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for input, want := range map[string]Version{"v1": V1, "V2": V2, "hybrid": Hybrid} {
+		got, err := ParseVersion(input)
+		if err != nil || got != want {
+			t.Errorf("ParseVersion(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseVersion("v3"); err == nil {
+		t.Error("ParseVersion(v3) expected error, got nil")
+	}
+}
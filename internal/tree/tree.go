@@ -0,0 +1,171 @@
+// Package tree computes Merkle tree hashes that fingerprint whole directories.
+//
+// Every directory digest is the hash of its sorted entries, each encoded as
+//
+//	<kind> <name> NUL <digest>
+//
+// where kind is "file", "exec" (executable file, only when modes are
+// included), "link" or "dir", name is the entry's base name in UTF-8 and
+// digest is the raw file hash, hash of the link target or subdirectory
+// digest. Because a directory's digest
+// covers those of its children, a changed file can be localized by comparing
+// digests from the root downwards. Empty directories are not represented.
+package tree
+
+import (
+	"fmt"
+	"hash"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+// File is a file to include in a tree.
+type File struct {
+	Path    string            // Slash-separated path relative to the tree root
+	Size    int64             // File size in bytes
+	Mode    fs.FileMode       // File mode (the executable bit is only used when Options.Modes is set)
+	Digests map[string][]byte // Algorithm name -> raw file digest, or digest of the target of a symlink
+}
+
+// Options configures tree hashing.
+type Options struct {
+	// Modes includes the executable bit of files in the tree, as git does.
+	// Other permission bits are ignored so trees compare equal across umasks.
+	Modes bool
+}
+
+// Node is a directory in a hashed tree.
+type Node struct {
+	Path    string            // Slash-separated path relative to the root ("." for the root)
+	Size    int64             // Total size of all files below this directory
+	Files   int               // Number of files below this directory
+	Digests map[string][]byte // Algorithm name -> directory digest
+	Dirs    []*Node           // Subdirectories sorted by name
+
+	files map[string]File
+}
+
+// Build computes the tree of the given files for each hasher.
+// Every file must carry a digest for every hasher. Fuzzy hashers are rejected
+// because their digests are not fixed-size.
+func Build(hashers []hasher.Hasher, files []File, opts Options) (*Node, error) {
+	for _, h := range hashers {
+		if _, ok := h.(hasher.FuzzyHasher); ok {
+			return nil, fmt.Errorf("algorithm %s cannot be used for tree hashes", h.Name())
+		}
+	}
+
+	root := &Node{Path: ".", files: make(map[string]File)}
+	dirs := map[string]*Node{".": root}
+
+	for _, f := range files {
+		p := path.Clean(f.Path)
+		if p == "." || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, fmt.Errorf("invalid tree path: %q", f.Path)
+		}
+		for _, h := range hashers {
+			if len(f.Digests[h.Name()]) != h.OutputSize() {
+				return nil, fmt.Errorf("%s: missing or invalid %s digest", f.Path, h.Name())
+			}
+		}
+
+		dir := parent(root, dirs, path.Dir(p))
+		name := path.Base(p)
+		if _, exists := dir.files[name]; exists {
+			return nil, fmt.Errorf("duplicate tree path: %q", f.Path)
+		}
+		dir.files[name] = f
+	}
+
+	for _, n := range dirs {
+		for _, sub := range n.Dirs {
+			if _, exists := n.files[path.Base(sub.Path)]; exists {
+				return nil, fmt.Errorf("tree path is both a file and a directory: %q", sub.Path)
+			}
+		}
+	}
+
+	root.sum(hashers, opts)
+	return root, nil
+}
+
+// parent returns the node for directory p, creating it and its ancestors.
+func parent(root *Node, dirs map[string]*Node, p string) *Node {
+	if n, ok := dirs[p]; ok {
+		return n
+	}
+	up := parent(root, dirs, path.Dir(p))
+	n := &Node{Path: p, files: make(map[string]File)}
+	up.Dirs = append(up.Dirs, n)
+	dirs[p] = n
+	return n
+}
+
+// sum computes the digests of n and all of its subdirectories.
+func (n *Node) sum(hashers []hasher.Hasher, opts Options) {
+	slices.SortFunc(n.Dirs, func(a, b *Node) int { return strings.Compare(a.Path, b.Path) })
+	for _, d := range n.Dirs {
+		d.sum(hashers, opts)
+		n.Size += d.Size
+		n.Files += d.Files
+	}
+
+	// Entries sorted by name, files and directories interleaved
+	type entry struct {
+		name string
+		kind string
+		file File
+		dir  *Node
+	}
+	entries := make([]entry, 0, len(n.files)+len(n.Dirs))
+	for name, f := range n.files {
+		kind := "file"
+		if f.Mode&fs.ModeSymlink != 0 {
+			kind = "link"
+		} else if opts.Modes && f.Mode&0111 != 0 {
+			kind = "exec"
+		}
+		entries = append(entries, entry{name: name, kind: kind, file: f})
+		n.Size += f.Size
+		n.Files++
+	}
+	for _, d := range n.Dirs {
+		entries = append(entries, entry{name: path.Base(d.Path), kind: "dir", dir: d})
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.name, b.name) })
+
+	n.Digests = make(map[string][]byte, len(hashers))
+	for _, h := range hashers {
+		hh := h.New()
+		for _, e := range entries {
+			digest := e.file.Digests[h.Name()]
+			if e.dir != nil {
+				digest = e.dir.Digests[h.Name()]
+			}
+			writeEntry(hh, e.kind, e.name, digest)
+		}
+		n.Digests[h.Name()] = hh.Sum(nil)
+	}
+	n.files = nil
+}
+
+func writeEntry(h hash.Hash, kind, name string, digest []byte) {
+	h.Write([]byte(kind))
+	h.Write([]byte{' '})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(digest)
+}
+
+// Walk calls fn for n and every directory below it, parents before children
+// and siblings in name order.
+func (n *Node) Walk(fn func(*Node)) {
+	fn(n)
+	for _, d := range n.Dirs {
+		d.Walk(fn)
+	}
+}
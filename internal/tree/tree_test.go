@@ -0,0 +1,158 @@
+package tree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/fs"
+	"testing"
+
+	"github.com/Virace/fast-hasher/internal/hasher"
+)
+
+func sha256Hasher(t *testing.T) []hasher.Hasher {
+	t.Helper()
+	h, ok := hasher.Get("sha256")
+	if !ok {
+		t.Fatal("sha256 not registered")
+	}
+	return []hasher.Hasher{h}
+}
+
+func file(path, content string) File {
+	sum := sha256.Sum256([]byte(content))
+	return File{
+		Path:    path,
+		Size:    int64(len(content)),
+		Mode:    0644,
+		Digests: map[string][]byte{"sha256": sum[:]},
+	}
+}
+
+func TestBuild_Digest(t *testing.T) {
+	hashers := sha256Hasher(t)
+	a := file("a.txt", "a")
+	b := file("sub/b.txt", "b")
+
+	root, err := Build(hashers, []File{b, a}, Options{})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// Recompute by hand following the documented entry encoding
+	entry := func(kind, name string, digest []byte) []byte {
+		return append([]byte(kind+" "+name+"\x00"), digest...)
+	}
+	sub := sha256.Sum256(entry("file", "b.txt", b.Digests["sha256"]))
+	want := sha256.Sum256(append(entry("file", "a.txt", a.Digests["sha256"]), entry("dir", "sub", sub[:])...))
+
+	if !bytes.Equal(root.Digests["sha256"], want[:]) {
+		t.Errorf("root = %x, want %x", root.Digests["sha256"], want)
+	}
+	if root.Files != 2 || root.Size != 2 {
+		t.Errorf("root files = %d, size = %d, want 2, 2", root.Files, root.Size)
+	}
+	if len(root.Dirs) != 1 || root.Dirs[0].Path != "sub" || !bytes.Equal(root.Dirs[0].Digests["sha256"], sub[:]) {
+		t.Errorf("sub = %+v", root.Dirs)
+	}
+}
+
+func TestBuild_OrderIndependent(t *testing.T) {
+	hashers := sha256Hasher(t)
+	files := []File{file("a/1", "1"), file("b/2", "2"), file("a/c/3", "3"), file("0", "0")}
+	reversed := []File{files[3], files[2], files[1], files[0]}
+
+	r1, err := Build(hashers, files, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := Build(hashers, reversed, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r1.Digests["sha256"], r2.Digests["sha256"]) {
+		t.Error("root digest depends on input order")
+	}
+}
+
+func TestBuild_LocalizeChange(t *testing.T) {
+	hashers := sha256Hasher(t)
+	before, _ := Build(hashers, []File{file("a/1", "1"), file("b/2", "2")}, Options{})
+	after, _ := Build(hashers, []File{file("a/1", "1"), file("b/2", "changed")}, Options{})
+
+	digests := func(n *Node) map[string][]byte {
+		m := make(map[string][]byte)
+		n.Walk(func(d *Node) { m[d.Path] = d.Digests["sha256"] })
+		return m
+	}
+	b, a := digests(before), digests(after)
+
+	if bytes.Equal(b["."], a["."]) {
+		t.Error("root digest unchanged")
+	}
+	if !bytes.Equal(b["a"], a["a"]) {
+		t.Error("unrelated directory digest changed")
+	}
+	if bytes.Equal(b["b"], a["b"]) {
+		t.Error("changed directory digest unchanged")
+	}
+}
+
+func TestBuild_Modes(t *testing.T) {
+	hashers := sha256Hasher(t)
+	plain := file("run.sh", "x")
+	exec := plain
+	exec.Mode = 0755
+	group := plain
+	group.Mode = 0664
+
+	p, _ := Build(hashers, []File{plain}, Options{Modes: true})
+	e, _ := Build(hashers, []File{exec}, Options{Modes: true})
+	g, _ := Build(hashers, []File{group}, Options{Modes: true})
+	if bytes.Equal(p.Digests["sha256"], e.Digests["sha256"]) {
+		t.Error("executable bit not included with Modes")
+	}
+	if !bytes.Equal(p.Digests["sha256"], g.Digests["sha256"]) {
+		t.Error("non-executable permission bits must be ignored")
+	}
+
+	p, _ = Build(hashers, []File{plain}, Options{})
+	e, _ = Build(hashers, []File{exec}, Options{})
+	if !bytes.Equal(p.Digests["sha256"], e.Digests["sha256"]) {
+		t.Error("mode included without Modes")
+	}
+
+	// Symlinks are told apart from files with the same digest
+	link := plain
+	link.Mode = fs.ModeSymlink | 0777
+	l, _ := Build(hashers, []File{link}, Options{})
+	if bytes.Equal(p.Digests["sha256"], l.Digests["sha256"]) {
+		t.Error("symlink hashed as a file")
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	hashers := sha256Hasher(t)
+	tests := []struct {
+		name  string
+		files []File
+	}{
+		{name: "absolute path", files: []File{file("/a", "a")}},
+		{name: "parent path", files: []File{file("../a", "a")}},
+		{name: "duplicate", files: []File{file("a", "a"), file("./a", "b")}},
+		{name: "missing digest", files: []File{{Path: "a"}}},
+		{name: "file and directory", files: []File{file("a", "a"), file("a/b", "b")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Build(hashers, tt.files, Options{}); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+
+	ssdeep, _ := hasher.Get("ssdeep")
+	if _, err := Build([]hasher.Hasher{ssdeep}, nil, Options{}); err == nil {
+		t.Error("fuzzy hasher: expected error, got nil")
+	}
+}
@@ -0,0 +1,662 @@
+// Package blake3tree computes BLAKE3 subtree chaining values, so that a large
+// input can be split into segments that are hashed independently (and in
+// parallel) and then combined into the standard BLAKE3-256 digest.
+//
+// BLAKE3 hashes 1 KiB chunks and merges their chaining values in a binary
+// tree whose left subtrees are always complete, power-of-two sized trees.
+// Segments of SegmentChunks*ChunkSize bytes are therefore complete subtrees,
+// and only the final segment may be shorter.
+//
+// This is a portable implementation intended for segment hashing only; use
+// a regular BLAKE3 implementation for inputs that fit in a single segment.
+//
+// See: https://github.com/BLAKE3-team/BLAKE3-specs/blob/master/blake3.pdf
+package blake3tree
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	// ChunkSize is the size of a BLAKE3 chunk
+	ChunkSize = 1024
+	// BlockSize is the size of a BLAKE3 block
+	BlockSize = 64
+	// Size of the output checksum
+	Size = 32
+)
+
+const (
+	flagChunkStart = 1 << 0
+	flagChunkEnd   = 1 << 1
+	flagParent     = 1 << 2
+	flagRoot       = 1 << 3
+)
+
+var iv = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+// compress runs the BLAKE3 compression function and returns the first eight
+// output words, which are the chaining value (or the 32-byte root output).
+// The seven rounds are unrolled with the message permutation applied to the
+// word indices.
+func compress(cv *[8]uint32, m *[16]uint32, counter uint64, blockLen, flags uint32) [8]uint32 {
+	m0, m1, m2, m3, m4, m5, m6, m7 := m[0], m[1], m[2], m[3], m[4], m[5], m[6], m[7]
+	m8, m9, m10, m11, m12, m13, m14, m15 := m[8], m[9], m[10], m[11], m[12], m[13], m[14], m[15]
+	v0, v1, v2, v3, v4, v5, v6, v7 := cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7]
+	v8, v9, v10, v11 := iv[0], iv[1], iv[2], iv[3]
+	v12, v13, v14, v15 := uint32(counter), uint32(counter>>32), blockLen, flags
+
+	// Round 1
+	v0 += v4 + m0
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m1
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m2
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m3
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m4
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m5
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m6
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m7
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m8
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m9
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m10
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m11
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m12
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m13
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m14
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m15
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 2
+	v0 += v4 + m2
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m6
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m3
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m10
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m7
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m0
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m4
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m13
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m1
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m11
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m12
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m5
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m9
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m14
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m15
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m8
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 3
+	v0 += v4 + m3
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m4
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m10
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m12
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m13
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m2
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m7
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m14
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m6
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m5
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m9
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m0
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m11
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m15
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m8
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m1
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 4
+	v0 += v4 + m10
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m7
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m12
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m9
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m14
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m3
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m13
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m15
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m4
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m0
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m11
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m2
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m5
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m8
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m1
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m6
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 5
+	v0 += v4 + m12
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m13
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m9
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m11
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m15
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m10
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m14
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m8
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m7
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m2
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m5
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m3
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m0
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m1
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m6
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m4
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 6
+	v0 += v4 + m9
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m14
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m11
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m5
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m8
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m12
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m15
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m1
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m13
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m3
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m0
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m10
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m2
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m6
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m4
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m7
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+	// Round 7
+	v0 += v4 + m11
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m15
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m5
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m0
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m1
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m9
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m8
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m6
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+	v0 += v5 + m14
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m10
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m2
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m12
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m3
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m4
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m7
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m13
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+
+	return [8]uint32{v0 ^ v8, v1 ^ v9, v2 ^ v10, v3 ^ v11, v4 ^ v12, v5 ^ v13, v6 ^ v14, v7 ^ v15}
+}
+
+func parent(left, right *[8]uint32, flags uint32) [8]uint32 {
+	var m [16]uint32
+	copy(m[:8], left[:])
+	copy(m[8:], right[:])
+	return compress(&iv, &m, 0, BlockSize, flagParent|flags)
+}
+
+// Subtree computes the chaining value of a run of chunks starting at a given
+// chunk index. It implements hash.Hash; Sum returns the 32-byte chaining
+// value rather than a digest.
+type Subtree struct {
+	start  uint64      // index of the first chunk
+	chunks uint64      // completed chunks
+	stack  [][8]uint32 // chaining values of completed subtrees
+	cv     [8]uint32   // chaining value of the current chunk
+	block  [BlockSize]byte
+	blockN int // bytes in block
+	blocks int // completed blocks in the current chunk
+}
+
+// NewSubtree returns a Subtree whose first chunk has index startChunk.
+// For the result to be combinable, startChunk must be a multiple of the
+// (power-of-two) number of chunks in every segment.
+func NewSubtree(startChunk uint64) *Subtree {
+	s := &Subtree{start: startChunk}
+	s.Reset()
+	return s
+}
+
+// Reset resets the Subtree to its initial state.
+func (s *Subtree) Reset() {
+	s.chunks = 0
+	s.stack = s.stack[:0]
+	s.cv = iv
+	s.blockN = 0
+	s.blocks = 0
+}
+
+// Size returns the number of bytes Sum will return.
+func (s *Subtree) Size() int { return Size }
+
+// BlockSize returns the hash's underlying block size.
+func (s *Subtree) BlockSize() int { return BlockSize }
+
+func (s *Subtree) chunkFlags() uint32 {
+	if s.blocks == 0 {
+		return flagChunkStart
+	}
+	return 0
+}
+
+// Write (via the embedded io.Writer interface) adds more data to the running hash.
+// It never returns an error.
+func (s *Subtree) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		// A full block is only compressed once more data arrives, since the
+		// last block of a chunk needs the CHUNK_END flag.
+		if s.blockN == BlockSize {
+			if s.blocks == ChunkSize/BlockSize-1 {
+				s.pushChunk(s.finishChunk())
+			} else {
+				var m [16]uint32
+				words(&m, &s.block)
+				s.cv = compress(&s.cv, &m, s.start+s.chunks, BlockSize, s.chunkFlags())
+				s.blocks++
+				s.blockN = 0
+			}
+		}
+		n := copy(s.block[s.blockN:], p)
+		s.blockN += n
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// finishChunk compresses the last block of the current chunk and returns the
+// chunk chaining value. It does not reset the chunk state.
+func (s *Subtree) finishChunk() [8]uint32 {
+	var block [BlockSize]byte
+	copy(block[:], s.block[:s.blockN])
+	var m [16]uint32
+	words(&m, &block)
+	return compress(&s.cv, &m, s.start+s.chunks, uint32(s.blockN), s.chunkFlags()|flagChunkEnd)
+}
+
+// pushChunk adds a completed chunk and merges complete subtrees.
+func (s *Subtree) pushChunk(cv [8]uint32) {
+	s.chunks++
+	for n := s.chunks; n&1 == 0; n >>= 1 {
+		left := s.stack[len(s.stack)-1]
+		s.stack = s.stack[:len(s.stack)-1]
+		cv = parent(&left, &cv, 0)
+	}
+	s.stack = append(s.stack, cv)
+	s.cv = iv
+	s.blocks = 0
+	s.blockN = 0
+}
+
+// Sum appends the chaining value of the data written so far to b.
+// It does not change the underlying hash state.
+func (s *Subtree) Sum(b []byte) []byte {
+	cv := s.finishChunk()
+	for i := len(s.stack) - 1; i >= 0; i-- {
+		cv = parent(&s.stack[i], &cv, 0)
+	}
+	return appendWords(b, &cv)
+}
+
+// Root combines the chaining values of two or more consecutive segments into
+// the BLAKE3-256 digest. All segments but the last must have the same
+// power-of-two number of chunks.
+func Root(cvs [][]byte) []byte {
+	nodes := make([][8]uint32, len(cvs))
+	for i, cv := range cvs {
+		for j := range nodes[i] {
+			nodes[i][j] = binary.LittleEndian.Uint32(cv[4*j:])
+		}
+	}
+	root := merge(nodes, flagRoot)
+	return appendWords(nil, &root)
+}
+
+// merge builds the left-heavy tree over nodes: the left subtree holds the
+// largest power of two strictly less than len(nodes) nodes.
+func merge(nodes [][8]uint32, flags uint32) [8]uint32 {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	split := 1 << (bits.Len(uint(len(nodes)-1)) - 1)
+	left := merge(nodes[:split], 0)
+	right := merge(nodes[split:], 0)
+	return parent(&left, &right, flags)
+}
+
+func words(m *[16]uint32, block *[BlockSize]byte) {
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+}
+
+func appendWords(b []byte, w *[8]uint32) []byte {
+	for _, v := range w {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+var _ hash.Hash = (*Subtree)(nil)
@@ -0,0 +1,88 @@
+package blake3tree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zeebo/blake3"
+)
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// segmentedSum hashes data in segments of segmentChunks chunks and combines them.
+func segmentedSum(data []byte, segmentChunks int) []byte {
+	segment := segmentChunks * ChunkSize
+	var cvs [][]byte
+	for i := 0; i*segment < len(data); i++ {
+		end := min((i+1)*segment, len(data))
+		s := NewSubtree(uint64(i * segmentChunks))
+		s.Write(data[i*segment : end])
+		cvs = append(cvs, s.Sum(nil))
+	}
+	return Root(cvs)
+}
+
+func TestRoot_MatchesBLAKE3(t *testing.T) {
+	sizes := []int{
+		ChunkSize + 1,
+		2 * ChunkSize,
+		2*ChunkSize + 1,
+		3*ChunkSize - 1,
+		5 * ChunkSize,
+		7*ChunkSize + 100,
+		16 * ChunkSize,
+		31*ChunkSize + 1023,
+		100 * ChunkSize,
+	}
+
+	for _, segmentChunks := range []int{1, 2, 4} {
+		for _, size := range sizes {
+			if size <= segmentChunks*ChunkSize {
+				continue // a single segment is hashed as a root chunk
+			}
+			data := testData(size)
+			want := blake3.Sum256(data)
+			if got := segmentedSum(data, segmentChunks); !bytes.Equal(got, want[:]) {
+				t.Errorf("size %d, %d chunks/segment: got %x, want %x", size, segmentChunks, got, want)
+			}
+		}
+	}
+}
+
+func TestSubtree_IncrementalWrites(t *testing.T) {
+	data := testData(4 * ChunkSize)
+
+	whole := NewSubtree(0)
+	whole.Write(data)
+
+	pieces := NewSubtree(0)
+	for i := 0; i < len(data); i += 37 {
+		pieces.Write(data[i:min(i+37, len(data))])
+	}
+
+	if !bytes.Equal(whole.Sum(nil), pieces.Sum(nil)) {
+		t.Error("chaining value depends on write sizes")
+	}
+
+	// Sum must not change the state
+	before := pieces.Sum(nil)
+	if !bytes.Equal(before, pieces.Sum(nil)) {
+		t.Error("Sum changed the state")
+	}
+}
+
+func BenchmarkSubtree(b *testing.B) {
+	data := testData(1024 * 1024)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		s := NewSubtree(0)
+		s.Write(data)
+		s.Sum(nil)
+	}
+}
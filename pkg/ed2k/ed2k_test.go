@@ -0,0 +1,107 @@
+package ed2k
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/Virace/fast-hasher/pkg/md4"
+)
+
+func TestED2K(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		expected string
+	}{
+		{
+			name:     "empty",
+			size:     0,
+			expected: "31d6cfe0d16ae931b73c59d7e0c089c0",
+		},
+		{
+			// Well-known eMule vector for a single zero-filled chunk
+			name:     "one chunk of zeros",
+			size:     ChunkSize,
+			expected: "fc21d9af828f92a8df64beac3357425d",
+		},
+		{
+			name:     "two chunks of zeros",
+			size:     2 * ChunkSize,
+			expected: "114b21c63a74b6ca922291a11177dd5c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New()
+			h.Write(make([]byte, tt.size))
+			got := hex.EncodeToString(h.Sum(nil))
+			if got != tt.expected {
+				t.Errorf("ED2K(%d zero bytes) = %s, want %s", tt.size, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestED2K_SmallFileIsMD4(t *testing.T) {
+	data := []byte("hello world")
+	h := New()
+	h.Write(data)
+
+	expected := md4.Sum(data)
+	if got := h.Sum(nil); !bytes.Equal(got, expected[:]) {
+		t.Errorf("got %x, want %x", got, expected)
+	}
+}
+
+func TestED2K_MultiChunk(t *testing.T) {
+	data := make([]byte, ChunkSize+1000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	// Reference: MD4 over the concatenated MD4 digests of each chunk
+	first := md4.Sum(data[:ChunkSize])
+	second := md4.Sum(data[ChunkSize:])
+	expected := md4.Sum(append(first[:], second[:]...))
+
+	// Write in uneven pieces to exercise chunk boundary handling
+	h := New()
+	for i := 0; i < len(data); i += 1 << 20 {
+		end := min(i+1<<20, len(data))
+		h.Write(data[i:end])
+	}
+
+	if got := h.Sum(nil); !bytes.Equal(got, expected[:]) {
+		t.Errorf("got %x, want %x", got, expected)
+	}
+
+	h.Reset()
+	h.Write([]byte("hello world"))
+	small := md4.Sum([]byte("hello world"))
+	if got := h.Sum(nil); !bytes.Equal(got, small[:]) {
+		t.Errorf("after Reset: got %x, want %x", got, small)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	data := make([]byte, 2*ChunkSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	for _, size := range []int{0, 11, ChunkSize, ChunkSize + 1, 2 * ChunkSize} {
+		var chunks [][]byte
+		for off := 0; off < size || off == 0; off += ChunkSize {
+			sum := md4.Sum(data[off:min(off+ChunkSize, size)])
+			chunks = append(chunks, sum[:])
+		}
+
+		h := New()
+		h.Write(data[:size])
+		if got, want := Combine(chunks, int64(size)), h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("size %d: Combine = %x, want %x", size, got, want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package fastcdc implements FastCDC content-defined chunking.
+//
+// FastCDC finds chunk boundaries with a gear rolling hash, skips the first
+// MinSize bytes of every chunk and uses normalized chunking: a stricter mask
+// before the average size and a looser one after it, which concentrates chunk
+// sizes around AvgSize. Because boundaries depend only on nearby content,
+// inserting or removing data shifts boundaries only locally, so identical
+// regions of different files produce identical chunks.
+//
+// Boundaries are stable for a given set of options but are not intended to
+// match other FastCDC implementations, which use different gear tables.
+//
+// See: Xia et al., "FastCDC: a Fast and Efficient Content-Defined Chunking
+// Approach for Data Deduplication", USENIX ATC 2016.
+package fastcdc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+const (
+	// MinimumMinSize is the smallest allowed MinSize
+	MinimumMinSize = 64
+	// MaximumMaxSize is the largest allowed MaxSize
+	MaximumMaxSize = 1 << 30
+	// normalization is the number of mask bits added before and removed
+	// after the average size (normalized chunking level 2)
+	normalization = 2
+)
+
+// Options configures chunk sizes in bytes.
+type Options struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+// DefaultOptions returns chunk sizes around the given average: a quarter of
+// it as minimum and four times it as maximum.
+func DefaultOptions(avg int) Options {
+	return Options{MinSize: avg / 4, AvgSize: avg, MaxSize: avg * 4}
+}
+
+// Validate checks that the sizes are usable.
+func (o Options) Validate() error {
+	if o.MinSize < MinimumMinSize {
+		return fmt.Errorf("minimum chunk size must be at least %d bytes", MinimumMinSize)
+	}
+	if o.MaxSize > MaximumMaxSize {
+		return fmt.Errorf("maximum chunk size must be at most %d bytes", MaximumMaxSize)
+	}
+	if o.MinSize > o.AvgSize || o.AvgSize > o.MaxSize {
+		return fmt.Errorf("chunk sizes must satisfy min <= avg <= max (got %d, %d, %d)", o.MinSize, o.AvgSize, o.MaxSize)
+	}
+	return nil
+}
+
+// gear is the table of random values mixed into the rolling hash, generated
+// with splitmix64 so that it is fixed across builds.
+var gear [256]uint64
+
+func init() {
+	x := uint64(0x66617374636463) // "fastcdc"
+	for i := range gear {
+		x += 0x9E3779B97F4A7C15
+		z := x
+		z = (z ^ z>>30) * 0xBF58476D1CE4E5B9
+		z = (z ^ z>>27) * 0x94D049BB133111EB
+		gear[i] = z ^ z>>31
+	}
+}
+
+// mask returns a mask of the n high bits. The gear hash shifts left, so high
+// bits depend on the most bytes of the window.
+func mask(n int) uint64 {
+	n = min(max(n, 1), 63)
+	return ^uint64(0) << (64 - n)
+}
+
+// Chunk is a chunk of the input.
+type Chunk struct {
+	Offset int64  // Offset of the chunk in the input
+	Length int    // Length of the chunk in bytes
+	Data   []byte // Chunk data, only valid until the next call to Next
+}
+
+// Chunker splits a stream into content-defined chunks.
+type Chunker struct {
+	r      io.Reader
+	opts   Options
+	maskS  uint64 // mask used before AvgSize
+	maskL  uint64 // mask used after AvgSize
+	buf    []byte
+	start  int // start of unconsumed data in buf
+	end    int // end of data in buf
+	offset int64
+	eof    bool
+}
+
+// NewChunker returns a Chunker reading from r.
+func NewChunker(r io.Reader, opts Options) (*Chunker, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	avgBits := bits.Len(uint(opts.AvgSize)) - 1
+	return &Chunker{
+		r:     r,
+		opts:  opts,
+		maskS: mask(avgBits + normalization),
+		maskL: mask(avgBits - normalization),
+		buf:   make([]byte, 2*opts.MaxSize),
+	}, nil
+}
+
+// Next returns the next chunk, or io.EOF when the input is exhausted.
+func (c *Chunker) Next() (Chunk, error) {
+	if err := c.fill(); err != nil {
+		return Chunk{}, err
+	}
+	if c.start == c.end {
+		return Chunk{}, io.EOF
+	}
+
+	data := c.buf[c.start:c.end]
+	n := c.cut(data)
+	chunk := Chunk{Offset: c.offset, Length: n, Data: data[:n]}
+	c.start += n
+	c.offset += int64(n)
+	return chunk, nil
+}
+
+// fill reads until at least MaxSize bytes are buffered or the input ends.
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.start >= c.opts.MaxSize {
+		return nil
+	}
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+
+	for c.end < c.opts.MaxSize {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cut returns the length of the chunk at the start of data.
+func (c *Chunker) cut(data []byte) int {
+	n := min(len(data), c.opts.MaxSize)
+	if n <= c.opts.MinSize {
+		return n
+	}
+	normal := min(n, c.opts.AvgSize)
+
+	var fp uint64
+	i := c.opts.MinSize
+	for ; i < normal; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
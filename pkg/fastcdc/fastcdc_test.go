@@ -0,0 +1,111 @@
+package fastcdc
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunks(t *testing.T, data []byte, opts Options) []Chunk {
+	t.Helper()
+	c, err := NewChunker(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatalf("NewChunker failed: %v", err)
+	}
+	var result []Chunk
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		chunk.Data = append([]byte(nil), chunk.Data...)
+		result = append(result, chunk)
+	}
+}
+
+func TestChunker_CoversInput(t *testing.T) {
+	opts := DefaultOptions(4096)
+	data := randomData(1, 1<<20)
+
+	var offset int64
+	var joined []byte
+	list := chunks(t, data, opts)
+	for i, c := range list {
+		if c.Offset != offset {
+			t.Fatalf("chunk %d offset = %d, want %d", i, c.Offset, offset)
+		}
+		if c.Length > opts.MaxSize || (c.Length < opts.MinSize && i != len(list)-1) {
+			t.Errorf("chunk %d length %d outside [%d, %d]", i, c.Length, opts.MinSize, opts.MaxSize)
+		}
+		offset += int64(c.Length)
+		joined = append(joined, c.Data...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("chunks do not reassemble the input")
+	}
+
+	// Normalized chunking keeps the mean near the average size
+	mean := len(data) / len(list)
+	if mean < opts.AvgSize/2 || mean > opts.AvgSize*2 {
+		t.Errorf("mean chunk size %d too far from %d", mean, opts.AvgSize)
+	}
+}
+
+func TestChunker_ShiftResistant(t *testing.T) {
+	opts := DefaultOptions(4096)
+	data := randomData(2, 256*1024)
+	shifted := append(randomData(3, 100), data...)
+
+	seen := make(map[string]bool)
+	for _, c := range chunks(t, data, opts) {
+		seen[string(c.Data)] = true
+	}
+	shared := 0
+	list := chunks(t, shifted, opts)
+	for _, c := range list {
+		if seen[string(c.Data)] {
+			shared++
+		}
+	}
+	// Only the chunks around the insertion may differ
+	if shared < len(list)-2 {
+		t.Errorf("only %d of %d chunks survived a 100-byte insertion", shared, len(list))
+	}
+}
+
+func TestChunker_Small(t *testing.T) {
+	opts := DefaultOptions(4096)
+	if list := chunks(t, nil, opts); len(list) != 0 {
+		t.Errorf("got %d chunks for empty input, want 0", len(list))
+	}
+	if list := chunks(t, []byte("tiny"), opts); len(list) != 1 || list[0].Length != 4 {
+		t.Errorf("got %+v for tiny input", list)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	invalid := []Options{
+		{MinSize: 16, AvgSize: 4096, MaxSize: 16384},
+		{MinSize: 8192, AvgSize: 4096, MaxSize: 16384},
+		{MinSize: 1024, AvgSize: 4096, MaxSize: 2048},
+		{MinSize: 1024, AvgSize: 4096, MaxSize: MaximumMaxSize + 1},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected error, got nil", opts)
+		}
+	}
+	if err := DefaultOptions(65536).Validate(); err != nil {
+		t.Errorf("DefaultOptions: %v", err)
+	}
+}
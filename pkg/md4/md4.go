@@ -0,0 +1,175 @@
+// Package md4 implements the MD4 hash algorithm as defined in RFC 1320.
+//
+// MD4 is cryptographically broken and must not be used for security
+// purposes. It is provided because the eDonkey/eMule (ED2K) file hash is
+// built on top of it.
+//
+// This code was ported from golang.org/x/crypto/md4.
+// Copyright 2009 The Go Authors. Licensed under the BSD 3-Clause License.
+package md4
+
+import (
+	"hash"
+	"math/bits"
+)
+
+const (
+	// Size of the output checksum
+	Size = 16
+	// BlockSize is the block size of MD4 in bytes
+	BlockSize = 64
+
+	init0 = 0x67452301
+	init1 = 0xEFCDAB89
+	init2 = 0x98BADCFE
+	init3 = 0x10325476
+)
+
+type digest struct {
+	s   [4]uint32
+	x   [BlockSize]byte
+	nx  int
+	len uint64
+}
+
+// New returns a new hash.Hash computing the MD4 checksum.
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+// Reset resets the Hash to its initial state.
+func (d *digest) Reset() {
+	d.s[0] = init0
+	d.s[1] = init1
+	d.s[2] = init2
+	d.s[3] = init3
+	d.nx = 0
+	d.len = 0
+}
+
+// Size returns the number of bytes Sum will return.
+func (d *digest) Size() int { return Size }
+
+// BlockSize returns the hash's underlying block size.
+func (d *digest) BlockSize() int { return BlockSize }
+
+// Write (via the embedded io.Writer interface) adds more data to the running hash.
+// It never returns an error.
+func (d *digest) Write(p []byte) (int, error) {
+	nn := len(p)
+	d.len += uint64(nn)
+	if d.nx > 0 {
+		n := copy(d.x[d.nx:], p)
+		d.nx += n
+		if d.nx == BlockSize {
+			block(d, d.x[:])
+			d.nx = 0
+		}
+		p = p[n:]
+	}
+	n := block(d, p)
+	p = p[n:]
+	if len(p) > 0 {
+		d.nx = copy(d.x[:], p)
+	}
+	return nn, nil
+}
+
+// Sum appends the current hash to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (d *digest) Sum(b []byte) []byte {
+	// Make a copy of d so that the caller can keep writing and summing.
+	dd := *d
+
+	// Padding: add a 1 bit and 0 bits until 56 bytes mod 64.
+	length := dd.len
+	var tmp [64]byte
+	tmp[0] = 0x80
+	if length%64 < 56 {
+		dd.Write(tmp[0 : 56-length%64])
+	} else {
+		dd.Write(tmp[0 : 64+56-length%64])
+	}
+
+	// Length in bits, little endian.
+	length <<= 3
+	for i := range 8 {
+		tmp[i] = byte(length >> (8 * i))
+	}
+	dd.Write(tmp[0:8])
+
+	for _, s := range dd.s {
+		b = append(b, byte(s), byte(s>>8), byte(s>>16), byte(s>>24))
+	}
+	return b
+}
+
+// Sum returns the MD4 checksum of the data.
+func Sum(data []byte) (h [Size]byte) {
+	d := New()
+	_, _ = d.Write(data)
+	copy(h[:], d.Sum(nil))
+	return h
+}
+
+var (
+	shift1 = [4]int{3, 7, 11, 19}
+	shift2 = [4]int{3, 5, 9, 13}
+	shift3 = [4]int{3, 9, 11, 15}
+
+	xIndex2 = [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}
+	xIndex3 = [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}
+)
+
+// block processes as many whole 64-byte blocks of p as possible and
+// returns the number of bytes consumed.
+func block(dig *digest, p []byte) int {
+	a, b, c, d := dig.s[0], dig.s[1], dig.s[2], dig.s[3]
+	n := 0
+	var x [16]uint32
+	for len(p) >= BlockSize {
+		aa, bb, cc, dd := a, b, c, d
+
+		for i := range 16 {
+			j := i * 4
+			x[i] = uint32(p[j]) | uint32(p[j+1])<<8 | uint32(p[j+2])<<16 | uint32(p[j+3])<<24
+		}
+
+		// Round 1
+		for i := range 16 {
+			f := ((c ^ d) & b) ^ d
+			a += f + x[i]
+			a = bits.RotateLeft32(a, shift1[i%4])
+			a, b, c, d = d, a, b, c
+		}
+
+		// Round 2
+		for i := range 16 {
+			g := (b & c) | (b & d) | (c & d)
+			a += g + x[xIndex2[i]] + 0x5a827999
+			a = bits.RotateLeft32(a, shift2[i%4])
+			a, b, c, d = d, a, b, c
+		}
+
+		// Round 3
+		for i := range 16 {
+			h := b ^ c ^ d
+			a += h + x[xIndex3[i]] + 0x6ed9eba1
+			a = bits.RotateLeft32(a, shift3[i%4])
+			a, b, c, d = d, a, b, c
+		}
+
+		a += aa
+		b += bb
+		c += cc
+		d += dd
+
+		p = p[BlockSize:]
+		n += BlockSize
+	}
+
+	dig.s[0], dig.s[1], dig.s[2], dig.s[3] = a, b, c, d
+	return n
+}
@@ -0,0 +1,67 @@
+package md4
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMD4(t *testing.T) {
+	// Test vectors from RFC 1320, appendix A.5
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", "043f8582f241db351ce627e153e7f0e4"},
+		{strings.Repeat("1234567890", 8), "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			h := New()
+			h.Write([]byte(tt.input))
+			got := hex.EncodeToString(h.Sum(nil))
+			if got != tt.expected {
+				t.Errorf("MD4(%q) = %s, want %s", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMD4_Incremental(t *testing.T) {
+	data := bytes.Repeat([]byte("incremental md4 data "), 100)
+	expected := Sum(data)
+
+	for _, chunkSize := range []int{1, 7, 63, 64, 65, 1000} {
+		h := New()
+		for i := 0; i < len(data); i += chunkSize {
+			end := min(i+chunkSize, len(data))
+			h.Write(data[i:end])
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, expected[:]) {
+			t.Errorf("chunk size %d: got %x, want %x", chunkSize, got, expected)
+		}
+	}
+}
+
+func TestMD4_SumDoesNotChangeState(t *testing.T) {
+	h := New()
+	h.Write([]byte("hello"))
+	first := h.Sum(nil)
+	second := h.Sum(nil)
+	if !bytes.Equal(first, second) {
+		t.Errorf("Sum changed state: %x vs %x", first, second)
+	}
+
+	h.Reset()
+	h.Write([]byte("hello"))
+	if got := h.Sum(nil); !bytes.Equal(got, first) {
+		t.Errorf("after Reset: got %x, want %x", got, first)
+	}
+}
@@ -0,0 +1,144 @@
+package ssdeep
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidDigest is returned when a digest string cannot be parsed.
+var ErrInvalidDigest = errors.New("invalid ssdeep digest")
+
+type parsedDigest struct {
+	blockSize uint64
+	hash1     string
+	hash2     string
+}
+
+func parseDigest(s string) (parsedDigest, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return parsedDigest{}, ErrInvalidDigest
+	}
+	bs, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || bs == 0 {
+		return parsedDigest{}, ErrInvalidDigest
+	}
+	// Drop an optional ",filename" suffix as written by the ssdeep program
+	hash2, _, _ := strings.Cut(parts[2], ",")
+	return parsedDigest{
+		blockSize: bs,
+		hash1:     eliminateSequences(parts[1]),
+		hash2:     eliminateSequences(hash2),
+	}, nil
+}
+
+// eliminateSequences shortens runs of more than three identical characters,
+// which carry little information, to three characters.
+func eliminateSequences(s string) string {
+	if len(s) <= 3 {
+		return s
+	}
+	b := []byte(s[:3])
+	for i := 3; i < len(s); i++ {
+		if s[i] != s[i-1] || s[i] != s[i-2] || s[i] != s[i-3] {
+			b = append(b, s[i])
+		}
+	}
+	return string(b)
+}
+
+// Compare returns a match score between 0 (no similarity) and 100 (identical
+// or near identical) for two ssdeep digests.
+func Compare(a, b string) (int, error) {
+	da, err := parseDigest(a)
+	if err != nil {
+		return 0, err
+	}
+	db, err := parseDigest(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// Digests are only comparable if the block sizes are equal or adjacent
+	if da.blockSize != db.blockSize && da.blockSize != db.blockSize*2 && db.blockSize != da.blockSize*2 {
+		return 0, nil
+	}
+
+	if da.blockSize == db.blockSize && da.hash1 == db.hash1 {
+		return 100, nil
+	}
+
+	switch {
+	case da.blockSize == db.blockSize:
+		return max(
+			scoreStrings(da.hash1, db.hash1, da.blockSize),
+			scoreStrings(da.hash2, db.hash2, da.blockSize*2),
+		), nil
+	case da.blockSize == db.blockSize*2:
+		return scoreStrings(da.hash1, db.hash2, da.blockSize), nil
+	default:
+		return scoreStrings(da.hash2, db.hash1, db.blockSize), nil
+	}
+}
+
+// scoreStrings computes the match score of two hash parts at a block size.
+func scoreStrings(s1, s2 string, blockSize uint64) int {
+	if len(s1) > SpamSumLength || len(s2) > SpamSumLength {
+		return 0
+	}
+	// Unrelated inputs are very unlikely to share a whole rolling window
+	if !hasCommonSubstring(s1, s2) {
+		return 0
+	}
+
+	score := editDistance(s1, s2)
+	// Scale by the string lengths to a value between 0 and 100
+	score = score * SpamSumLength / (len(s1) + len(s2))
+	score = 100 * score / SpamSumLength
+	if score >= 100 {
+		return 0
+	}
+	score = 100 - score
+
+	// Small block sizes cannot produce confident matches for short digests
+	if blockSize >= (99+rollingWindow)/rollingWindow*MinBlockSize {
+		return score
+	}
+	limit := int(blockSize/MinBlockSize) * min(len(s1), len(s2))
+	return min(score, limit)
+}
+
+func hasCommonSubstring(s1, s2 string) bool {
+	if len(s1) < rollingWindow || len(s2) < rollingWindow {
+		return false
+	}
+	for i := 0; i+rollingWindow <= len(s2); i++ {
+		if strings.Contains(s1, s2[i:i+rollingWindow]) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the weighted Levenshtein distance used by ssdeep:
+// insertions and deletions cost 1, substitutions cost 2.
+func editDistance(s1, s2 string) int {
+	prev := make([]int, len(s2)+1)
+	cur := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		cur[0] = i
+		for j := 1; j <= len(s2); j++ {
+			cost := 2
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(s2)]
+}
@@ -0,0 +1,262 @@
+// Package ssdeep implements context triggered piecewise hashing (CTPH),
+// compatible with the ssdeep fuzzy hashing program.
+//
+// Digests are strings of the form "blocksize:hash1:hash2" and two digests
+// can be compared with Compare to obtain a match score from 0 to 100.
+//
+// The streaming algorithm follows ssdeep 2.13+, which tracks every candidate
+// block size at once so the input length does not need to be known upfront.
+//
+// See: https://ssdeep-project.github.io/ssdeep/
+package ssdeep
+
+import (
+	"hash"
+	"strconv"
+)
+
+const (
+	// SpamSumLength is the maximum length of each hash part of a digest
+	SpamSumLength = 64
+	// MinBlockSize is the smallest block size used
+	MinBlockSize = 3
+	// MaxResultLength is the maximum length of a digest string
+	MaxResultLength = 2*SpamSumLength + 20
+
+	rollingWindow  = 7
+	hashPrime      = 0x01000193
+	hashInit       = 0x28021967
+	numBlockHashes = 31
+)
+
+const b64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// rollState is the rolling hash used to find context trigger points.
+type rollState struct {
+	window     [rollingWindow]byte
+	h1, h2, h3 uint32
+	n          int
+}
+
+func (r *rollState) roll(c byte) {
+	r.h2 -= r.h1
+	r.h2 += rollingWindow * uint32(c)
+
+	r.h1 += uint32(c)
+	r.h1 -= uint32(r.window[r.n])
+
+	r.window[r.n] = c
+	r.n++
+	if r.n == rollingWindow {
+		r.n = 0
+	}
+
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+}
+
+func (r *rollState) sum() uint32 {
+	return r.h1 + r.h2 + r.h3
+}
+
+// sumHash is the FNV-style piecewise hash.
+func sumHash(c byte, h uint32) uint32 {
+	return (h * hashPrime) ^ uint32(c)
+}
+
+// blockHash is the piecewise hash state for one block size.
+type blockHash struct {
+	h, halfh   uint32
+	digest     [SpamSumLength]byte
+	halfDigest byte
+	dlen       int
+}
+
+type digest struct {
+	totalSize uint64
+	bhStart   int
+	bhEnd     int
+	bh        [numBlockHashes]blockHash
+	roll      rollState
+
+	needLastHash bool
+	lastHash     uint32
+}
+
+// New returns a new hash.Hash computing the ssdeep digest.
+// Sum appends the ASCII digest string ("blocksize:hash1:hash2").
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+func blockSize(index int) uint64 {
+	return MinBlockSize << index
+}
+
+// Reset resets the Hash to its initial state.
+func (d *digest) Reset() {
+	*d = digest{bhEnd: 1}
+	d.bh[0].h = hashInit
+	d.bh[0].halfh = hashInit
+}
+
+// Size returns the maximum number of bytes Sum will append.
+func (d *digest) Size() int { return MaxResultLength }
+
+// BlockSize returns the hash's underlying block size.
+func (d *digest) BlockSize() int { return 1 }
+
+// Write (via the embedded io.Writer interface) adds more data to the running hash.
+// It never returns an error.
+func (d *digest) Write(p []byte) (int, error) {
+	d.totalSize += uint64(len(p))
+	for _, c := range p {
+		d.step(c)
+	}
+	return len(p), nil
+}
+
+func (d *digest) tryForkBlockHash() {
+	obh := &d.bh[d.bhEnd-1]
+	if d.bhEnd < numBlockHashes {
+		nbh := &d.bh[d.bhEnd]
+		nbh.h = obh.h
+		nbh.halfh = obh.halfh
+		nbh.halfDigest = 0
+		nbh.dlen = 0
+		d.bhEnd++
+	} else if d.bhEnd == numBlockHashes && !d.needLastHash {
+		d.needLastHash = true
+		d.lastHash = obh.h
+	}
+}
+
+func (d *digest) tryReduceBlockHash() {
+	// Need at least two working hashes
+	if d.bhEnd-d.bhStart < 2 {
+		return
+	}
+	// Initial block size estimate would select this or a smaller block size
+	if blockSize(d.bhStart)*SpamSumLength >= d.totalSize {
+		return
+	}
+	// Estimate adjustment would select this block size
+	if d.bh[d.bhStart+1].dlen < SpamSumLength/2 {
+		return
+	}
+	d.bhStart++
+}
+
+func (d *digest) step(c byte) {
+	d.roll.roll(c)
+	h := uint64(d.roll.sum())
+
+	for i := d.bhStart; i < d.bhEnd; i++ {
+		d.bh[i].h = sumHash(c, d.bh[i].h)
+		d.bh[i].halfh = sumHash(c, d.bh[i].halfh)
+	}
+	if d.needLastHash {
+		d.lastHash = sumHash(c, d.lastHash)
+	}
+
+	for i := d.bhStart; i < d.bhEnd; i++ {
+		// Trigger points for larger block sizes are a subset of smaller ones
+		bs := blockSize(i)
+		if h%bs != bs-1 {
+			break
+		}
+
+		bh := &d.bh[i]
+		if bh.dlen == 0 {
+			// First trigger for this block size: start the next one
+			d.tryForkBlockHash()
+		}
+		bh.digest[bh.dlen] = b64[bh.h%64]
+		bh.halfDigest = b64[bh.halfh%64]
+		if bh.dlen < SpamSumLength-1 {
+			// Only reset the piecewise hash while there is room in the
+			// signature; otherwise the tail is merged into the last piece.
+			bh.dlen++
+			bh.h = hashInit
+			if bh.dlen < SpamSumLength/2 {
+				bh.halfh = hashInit
+				bh.halfDigest = 0
+			}
+		} else {
+			d.tryReduceBlockHash()
+		}
+	}
+}
+
+// pending returns the character for the unfinished last piece of bh, or 0 if none.
+func (d *digest) pending(bh *blockHash, h uint32) byte {
+	if d.roll.sum() != 0 {
+		return b64[h%64]
+	}
+	if bh.dlen == SpamSumLength-1 {
+		return bh.digest[bh.dlen]
+	}
+	return 0
+}
+
+// Sum appends the current digest string to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (d *digest) Sum(b []byte) []byte {
+	bi := d.bhStart
+
+	// Initial block size guess
+	for blockSize(bi)*SpamSumLength < d.totalSize {
+		bi++
+		if bi >= numBlockHashes {
+			bi = numBlockHashes - 1
+			break
+		}
+	}
+	// Adapt block size guess to actual digest length
+	for bi >= d.bhEnd {
+		bi--
+	}
+	for bi > d.bhStart && d.bh[bi].dlen < SpamSumLength/2 {
+		bi--
+	}
+
+	rollSum := d.roll.sum()
+
+	b = strconv.AppendUint(b, blockSize(bi), 10)
+	b = append(b, ':')
+
+	bh := &d.bh[bi]
+	b = append(b, bh.digest[:bh.dlen]...)
+	if c := d.pending(bh, bh.h); c != 0 {
+		b = append(b, c)
+	}
+	b = append(b, ':')
+
+	if bi < d.bhEnd-1 {
+		bh = &d.bh[bi+1]
+		n := min(bh.dlen, SpamSumLength/2-1)
+		b = append(b, bh.digest[:n]...)
+		if rollSum != 0 {
+			b = append(b, b64[bh.halfh%64])
+		} else if bh.halfDigest != 0 {
+			b = append(b, bh.halfDigest)
+		}
+	} else if rollSum != 0 {
+		if bi == 0 {
+			b = append(b, b64[bh.h%64])
+		} else {
+			b = append(b, b64[d.lastHash%64])
+		}
+	}
+
+	return b
+}
+
+// Sum returns the ssdeep digest string of the data.
+func Sum(data []byte) string {
+	d := New()
+	_, _ = d.Write(data)
+	return string(d.Sum(nil))
+}
@@ -0,0 +1,103 @@
+package ssdeep
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+const (
+	text1 = "Also called fuzzy hashes, Ctph can match inputs that have homologies."
+	text2 = "Also called fuzzy hashes, CTPH can match inputs that have homologies."
+)
+
+func TestSum(t *testing.T) {
+	// Vectors published with the python-ssdeep bindings
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{text1, "3:AXGBicFlgVNhBGcL6wCrFQEv:AXGHsNhxLsr2C"},
+		{text2, "3:AXGBicFlIHBGcL6wCrFQEv:AXGH6xLsr2C"},
+	}
+
+	for _, tt := range tests {
+		if got := Sum([]byte(tt.input)); got != tt.expected {
+			t.Errorf("Sum(%q) = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSum_Incremental(t *testing.T) {
+	data := make([]byte, 200*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	expected := Sum(data)
+
+	h := New()
+	for i := 0; i < len(data); i += 4093 {
+		end := min(i+4093, len(data))
+		h.Write(data[i:end])
+	}
+	if got := string(h.Sum(nil)); got != expected {
+		t.Errorf("incremental digest = %s, want %s", got, expected)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	score, err := Compare(Sum([]byte(text1)), Sum([]byte(text2)))
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if score != 22 {
+		t.Errorf("Compare() = %d, want 22", score)
+	}
+
+	d := Sum([]byte(text1))
+	if score, _ := Compare(d, d); score != 100 {
+		t.Errorf("Compare(identical) = %d, want 100", score)
+	}
+
+	if _, err := Compare("garbage", d); err == nil {
+		t.Error("Compare(invalid) expected error, got nil")
+	}
+}
+
+func TestCompare_NearDuplicate(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	original := make([]byte, 64*1024)
+	rng.Read(original)
+
+	modified := bytes.Clone(original)
+	copy(modified[30000:], []byte("a small patch in the middle of the file"))
+
+	unrelated := make([]byte, len(original))
+	rng.Read(unrelated)
+
+	near, err := Compare(Sum(original), Sum(modified))
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if near < 80 {
+		t.Errorf("near duplicate score = %d, want >= 80", near)
+	}
+
+	far, _ := Compare(Sum(original), Sum(unrelated))
+	if far != 0 {
+		t.Errorf("unrelated score = %d, want 0", far)
+	}
+}
+
+func TestEliminateSequences(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"abc":       "abc",
+		"aaaa":      "aaa",
+		"aaaaaabbb": "aaabbb",
+		"abbbbbbc":  "abbbc",
+	}
+	for input, want := range tests {
+		if got := eliminateSequences(input); got != want {
+			t.Errorf("eliminateSequences(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package tlsh
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidDigest is returned when a digest string cannot be parsed.
+var ErrInvalidDigest = errors.New("invalid tlsh digest")
+
+type parsedDigest struct {
+	checksum byte
+	lvalue   byte
+	q1Ratio  byte
+	q2Ratio  byte
+	code     []byte
+}
+
+func parseDigest(s string) (parsedDigest, error) {
+	// The version prefix is optional (digests from TLSH < 4 have none)
+	if strings.HasPrefix(s, "T1") {
+		s = s[2:]
+	}
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != checksumLength+2+codeSize {
+		return parsedDigest{}, ErrInvalidDigest
+	}
+	return parsedDigest{
+		checksum: raw[0],
+		lvalue:   swapByte(raw[1]),
+		q1Ratio:  raw[2] >> 4,
+		q2Ratio:  raw[2] & 0x0F,
+		code:     raw[3:],
+	}, nil
+}
+
+// Diff returns the distance between two TLSH digests, including the length
+// component. 0 means identical; scores below about 100 usually indicate
+// closely related inputs.
+func Diff(a, b string) (int, error) {
+	da, err := parseDigest(a)
+	if err != nil {
+		return 0, err
+	}
+	db, err := parseDigest(b)
+	if err != nil {
+		return 0, err
+	}
+
+	diff := 0
+
+	switch ldiff := modDiff(int(da.lvalue), int(db.lvalue), 256); ldiff {
+	case 0, 1:
+		diff += ldiff
+	default:
+		diff += ldiff * 12
+	}
+
+	for _, qdiff := range []int{
+		modDiff(int(da.q1Ratio), int(db.q1Ratio), 16),
+		modDiff(int(da.q2Ratio), int(db.q2Ratio), 16),
+	} {
+		if qdiff <= 1 {
+			diff += qdiff
+		} else {
+			diff += (qdiff - 1) * 12
+		}
+	}
+
+	if da.checksum != db.checksum {
+		diff++
+	}
+
+	for i := range da.code {
+		diff += bitPairsDiff(da.code[i], db.code[i])
+	}
+	return diff, nil
+}
+
+// modDiff is the circular distance between x and y in a range of size r.
+func modDiff(x, y, r int) int {
+	var dl, dr int
+	if y > x {
+		dl = y - x
+		dr = x + r - y
+	} else {
+		dl = x - y
+		dr = y + r - x
+	}
+	return min(dl, dr)
+}
+
+// bitPairsDiff sums the differences of the four 2-bit quartile codes in a
+// byte, with a maximal difference (0 vs 3) weighted as 6.
+func bitPairsDiff(x, y byte) int {
+	diff := 0
+	for range 4 {
+		d := int(x&3) - int(y&3)
+		if d < 0 {
+			d = -d
+		}
+		if d == 3 {
+			d = 6
+		}
+		diff += d
+		x >>= 2
+		y >>= 2
+	}
+	return diff
+}
@@ -0,0 +1,191 @@
+// Package tlsh implements the Trend Micro Locality Sensitive Hash (TLSH)
+// with 128 buckets and a 1-byte checksum, the default TLSH configuration.
+//
+// Digests are written as "T1" followed by 70 uppercase hex characters. Inputs
+// that are too short or too uniform to produce a meaningful digest yield
+// "TNULL". Two digests can be compared with Diff, which returns a distance
+// where 0 means identical and larger values mean less similar.
+//
+// See: https://github.com/trendmicro/tlsh
+package tlsh
+
+import (
+	"encoding/hex"
+	"hash"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	// Size is the length of a digest string in bytes
+	Size = 2 + 2*(checksumLength+2+codeSize)
+	// MinDataLength is the minimum input length for a valid digest
+	MinDataLength = 50
+	// Null is the digest of inputs that cannot be hashed meaningfully
+	Null = "TNULL"
+
+	buckets        = 256
+	effBuckets     = 128
+	codeSize       = 32 // effBuckets * 2 bits / 8
+	checksumLength = 1
+	windowLength   = 5
+)
+
+// vTable is the Pearson hashing permutation used by TLSH.
+var vTable = [256]byte{
+	1, 87, 49, 12, 176, 178, 102, 166, 121, 193, 6, 84, 249, 230, 44, 163,
+	14, 197, 213, 181, 161, 85, 218, 80, 64, 239, 24, 226, 236, 142, 38, 200,
+	110, 177, 104, 103, 141, 253, 255, 50, 77, 101, 81, 18, 45, 96, 31, 222,
+	25, 107, 190, 70, 86, 237, 240, 34, 72, 242, 20, 214, 244, 227, 149, 235,
+	97, 234, 57, 22, 60, 250, 82, 175, 208, 5, 127, 199, 111, 62, 135, 248,
+	174, 169, 211, 58, 66, 154, 106, 195, 245, 171, 17, 187, 182, 179, 0, 243,
+	132, 56, 148, 75, 128, 133, 158, 100, 130, 126, 91, 13, 153, 246, 216, 219,
+	119, 68, 223, 78, 83, 88, 201, 99, 122, 11, 92, 32, 136, 114, 52, 10,
+	138, 30, 48, 183, 156, 35, 61, 26, 143, 74, 251, 94, 129, 162, 63, 152,
+	170, 7, 115, 167, 241, 206, 3, 150, 55, 59, 151, 220, 90, 53, 23, 131,
+	125, 173, 15, 238, 79, 95, 89, 16, 105, 137, 225, 224, 217, 160, 37, 123,
+	118, 73, 2, 157, 46, 116, 9, 145, 134, 228, 207, 212, 202, 215, 69, 229,
+	27, 188, 67, 124, 168, 252, 42, 4, 29, 108, 21, 247, 19, 205, 39, 203,
+	233, 40, 186, 147, 198, 192, 155, 33, 164, 191, 98, 204, 165, 180, 117, 76,
+	140, 36, 210, 172, 41, 54, 159, 8, 185, 232, 113, 196, 231, 47, 146, 120,
+	51, 65, 28, 144, 254, 221, 93, 189, 194, 139, 112, 43, 71, 109, 184, 209,
+}
+
+// bMapping hashes a salt and three bytes into a bucket index.
+func bMapping(salt, i, j, k byte) byte {
+	return vTable[vTable[vTable[vTable[salt]^i]^j]^k]
+}
+
+type digest struct {
+	window   [windowLength]byte
+	bucket   [buckets]uint32
+	checksum byte
+	dataLen  uint64
+}
+
+// New returns a new hash.Hash computing the TLSH digest.
+// Sum appends the ASCII digest string ("T1..." or "TNULL").
+func New() hash.Hash {
+	return &digest{}
+}
+
+// Reset resets the Hash to its initial state.
+func (d *digest) Reset() {
+	*d = digest{}
+}
+
+// Size returns the maximum number of bytes Sum will append.
+func (d *digest) Size() int { return Size }
+
+// BlockSize returns the hash's underlying block size.
+func (d *digest) BlockSize() int { return 1 }
+
+// Write (via the embedded io.Writer interface) adds more data to the running hash.
+// It never returns an error.
+func (d *digest) Write(p []byte) (int, error) {
+	for _, c := range p {
+		j := int(d.dataLen % windowLength)
+		d.window[j] = c
+		if d.dataLen >= windowLength-1 {
+			b0 := c
+			b1 := d.window[(j+4)%windowLength]
+			b2 := d.window[(j+3)%windowLength]
+			b3 := d.window[(j+2)%windowLength]
+			b4 := d.window[(j+1)%windowLength]
+
+			d.checksum = bMapping(0, b0, b1, d.checksum)
+
+			d.bucket[bMapping(2, b0, b1, b2)]++
+			d.bucket[bMapping(3, b0, b1, b3)]++
+			d.bucket[bMapping(5, b0, b2, b3)]++
+			d.bucket[bMapping(7, b0, b2, b4)]++
+			d.bucket[bMapping(11, b0, b1, b4)]++
+			d.bucket[bMapping(13, b0, b3, b4)]++
+		}
+		d.dataLen++
+	}
+	return len(p), nil
+}
+
+// Sum appends the current digest string to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (d *digest) Sum(b []byte) []byte {
+	if d.dataLen < MinDataLength {
+		return append(b, Null...)
+	}
+
+	sorted := slices.Clone(d.bucket[:effBuckets])
+	slices.Sort(sorted)
+	q1 := sorted[effBuckets/4-1]
+	q2 := sorted[effBuckets/2-1]
+	q3 := sorted[effBuckets-effBuckets/4-1]
+	if q3 == 0 {
+		return append(b, Null...)
+	}
+
+	nonzero := 0
+	for _, c := range d.bucket[:effBuckets] {
+		if c > 0 {
+			nonzero++
+		}
+	}
+	if nonzero <= effBuckets/2 {
+		return append(b, Null...)
+	}
+
+	var raw [checksumLength + 2 + codeSize]byte
+	raw[0] = swapByte(d.checksum)
+	raw[1] = swapByte(lCapturing(d.dataLen))
+	q1Ratio := byte(uint32(float32(q1)*100/float32(q3)) % 16)
+	q2Ratio := byte(uint32(float32(q2)*100/float32(q3)) % 16)
+	raw[2] = q1Ratio<<4 | q2Ratio
+
+	// Each body byte packs four 2-bit bucket quartiles; bytes are written
+	// from the highest bucket group down.
+	for i := range codeSize {
+		var h byte
+		for j := range 4 {
+			k := d.bucket[4*i+j]
+			switch {
+			case q3 < k:
+				h += 3 << (j * 2)
+			case q2 < k:
+				h += 2 << (j * 2)
+			case q1 < k:
+				h += 1 << (j * 2)
+			}
+		}
+		raw[3+codeSize-1-i] = h
+	}
+
+	b = append(b, "T1"...)
+	return append(b, strings.ToUpper(hex.EncodeToString(raw[:]))...)
+}
+
+// Sum returns the TLSH digest string of the data.
+func Sum(data []byte) string {
+	d := New()
+	_, _ = d.Write(data)
+	return string(d.Sum(nil))
+}
+
+func swapByte(b byte) byte {
+	return b<<4 | b>>4
+}
+
+// lCapturing encodes the data length logarithmically into one byte.
+func lCapturing(n uint64) byte {
+	// TLSH computes the logarithm in single precision
+	l := float64(float32(math.Log(float64(n))))
+	var i int
+	switch {
+	case n <= 656:
+		i = int(math.Floor(l / 0.4054651))
+	case n <= 3199:
+		i = int(math.Floor(l/0.26236426 - 8.72777))
+	default:
+		i = int(math.Floor(l/0.095310180 - 62.5472))
+	}
+	return byte(i & 0xFF)
+}
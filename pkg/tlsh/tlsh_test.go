@@ -0,0 +1,111 @@
+package tlsh
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestSum_Format(t *testing.T) {
+	got := Sum(randomData(1, 4096))
+	if len(got) != Size {
+		t.Fatalf("digest length = %d, want %d: %s", len(got), Size, got)
+	}
+	if !strings.HasPrefix(got, "T1") {
+		t.Errorf("digest should start with T1: %s", got)
+	}
+	if strings.ToUpper(got) != got {
+		t.Errorf("digest should be uppercase hex: %s", got)
+	}
+}
+
+func TestSum_Null(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "empty", input: nil},
+		{name: "too short", input: []byte("short input")},
+		{name: "uniform", input: bytes.Repeat([]byte{'a'}, 1000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sum(tt.input); got != Null {
+				t.Errorf("Sum() = %s, want %s", got, Null)
+			}
+		})
+	}
+}
+
+func TestSum_Incremental(t *testing.T) {
+	data := randomData(2, 10000)
+	expected := Sum(data)
+
+	h := New()
+	for i := 0; i < len(data); i += 333 {
+		end := min(i+333, len(data))
+		h.Write(data[i:end])
+	}
+	if got := string(h.Sum(nil)); got != expected {
+		t.Errorf("incremental digest = %s, want %s", got, expected)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	original := randomData(3, 32*1024)
+	modified := bytes.Clone(original)
+	copy(modified[1000:], []byte("a small patch that changes a few bytes"))
+	unrelated := randomData(4, 32*1024)
+
+	a, b, c := Sum(original), Sum(modified), Sum(unrelated)
+
+	if d, err := Diff(a, a); err != nil || d != 0 {
+		t.Errorf("Diff(identical) = %d, %v; want 0", d, err)
+	}
+
+	near, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	far, _ := Diff(a, c)
+	if near >= far {
+		t.Errorf("near duplicate distance %d should be below unrelated distance %d", near, far)
+	}
+	if near > 50 {
+		t.Errorf("near duplicate distance = %d, want <= 50", near)
+	}
+
+	// The version prefix is optional
+	if d, err := Diff(a, a[2:]); err != nil || d != 0 {
+		t.Errorf("Diff(without prefix) = %d, %v; want 0", d, err)
+	}
+
+	if _, err := Diff(a, Null); err == nil {
+		t.Error("Diff(TNULL) expected error, got nil")
+	}
+}
+
+func TestLCapturing(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want byte
+	}{
+		{656, 15},
+		{657, 16},
+		{3171, 21},
+		{3172, 22},
+	}
+	for _, tt := range tests {
+		if got := lCapturing(tt.n); got != tt.want {
+			t.Errorf("lCapturing(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}